@@ -0,0 +1,83 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/zztkm/zgyazo/internal/api"
+	"github.com/zztkm/zgyazo/internal/config"
+	"github.com/zztkm/zgyazo/internal/deadletter"
+	"github.com/zztkm/zgyazo/internal/history"
+	"github.com/zztkm/zgyazo/internal/uploader"
+)
+
+// recentUploadCount は API の状態で返す最近のアップロードの件数
+const recentUploadCount = 10
+
+// daemonAPI は常駐プロセスの状態と操作を API に公開する
+type daemonAPI struct {
+	uploader    *uploader.Uploader
+	history     *history.Store
+	deadLetters *deadletter.Store
+	reloader    *configReloader
+	startedAt   time.Time
+
+	// sigChan にシグナルを送って、シグナルを受け取った場合と同じように終了する
+	sigChan chan<- os.Signal
+}
+
+// startAPIServer は cfg で API を有効にしている場合にサーバーを起動する
+// 有効にしていない場合は nil を返す
+func startAPIServer(cfg *config.Config, backend api.Backend) (*api.Server, error) {
+	if cfg.API == nil {
+		return nil, nil
+	}
+	token, err := api.LoadOrCreateToken(api.TokenPath())
+	if err != nil {
+		return nil, err
+	}
+	server := api.NewServer(backend, cfg.API.ListenPort(), token)
+	if err := server.Start(); err != nil {
+		return nil, err
+	}
+	return server, nil
+}
+
+func (d *daemonAPI) Status() api.Status {
+	return api.Status{
+		Version:       versionString(),
+		PID:           os.Getpid(),
+		StartedAt:     d.startedAt,
+		Profile:       d.reloader.currentProfile(),
+		Status:        d.uploader.Status(),
+		DeadLetters:   d.deadLetters.Entries(),
+		RecentUploads: recentHistory(d.history, recentUploadCount),
+	}
+}
+
+func (d *daemonAPI) Pause(after time.Duration) {
+	d.reloader.pauseUploads(after)
+}
+
+func (d *daemonAPI) Resume() {
+	d.uploader.Resume(uploader.PauseUser)
+}
+
+func (d *daemonAPI) Enqueue(filePath string) error {
+	return d.uploader.Enqueue(filePath)
+}
+
+func (d *daemonAPI) RetryDeadLetters() (int, error) {
+	return d.uploader.RetryDeadLetters()
+}
+
+func (d *daemonAPI) Shutdown() {
+	slog.Info("shutdown requested via API")
+	select {
+	case d.sigChan <- syscall.SIGTERM:
+	default:
+		// すでに終了を始めている
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"strings"
+
+	"github.com/zztkm/zgyazo/gyazo"
+	"github.com/zztkm/zgyazo/internal/config"
+	"github.com/zztkm/zgyazo/internal/logging"
+)
+
+// version はビルド時に -ldflags "-X main.version=..." で設定される
+var version = ""
+
+// 環境変数名
+// 設定の優先順位は コマンドラインフラグ > 環境変数 > 設定ファイル
+const (
+	envConfig    = "ZGYAZO_CONFIG"
+	envToken     = "ZGYAZO_TOKEN"
+	envLogLevel  = "ZGYAZO_LOG_LEVEL"
+	envLogFormat = "ZGYAZO_LOG_FORMAT"
+	envEndpoint  = "ZGYAZO_ENDPOINT"
+	envWatch     = "ZGYAZO_WATCH"
+	envProfile   = "ZGYAZO_PROFILE"
+)
+
+const usage = `Usage: zgyazo [global flags] <command> [args]
+
+Commands:
+  run        ディレクトリを監視してアップロードする常駐プロセスを起動する (デフォルト)
+  upload     ファイルや標準入力の画像をアップロードする
+  delete     アップロードした画像を削除する
+  history    アップロードした画像の履歴を表示する
+  config     設定ファイルのパスや内容を表示する
+  secret     トークンを暗号化したキーストアに保存する
+  login      ブラウザで Gyazo にログインしてトークンを保存する
+  logout     保存したトークンを失効・削除する
+  doctor     設定や環境に問題がないかを確認する
+  logs       ログファイルを表示・共有用に書き出す
+  ctl        起動中の常駐プロセスの状態を確認・操作する
+  version    バージョンを表示する
+
+Global flags:
+%s
+Environment variables:
+  ZGYAZO_CONFIG         設定ファイルのパス (--config)
+  ZGYAZO_TOKEN          Gyazo API アクセストークン (config の gyazo_access_token)
+  ZGYAZO_LOG_LEVEL      ログレベル (--log-level)
+  ZGYAZO_LOG_FORMAT     ログの形式 (--log-format)
+  ZGYAZO_ENDPOINT       Gyazo API のエンドポイント (--endpoint)
+  ZGYAZO_WATCH          監視するディレクトリ (--watch)
+  ZGYAZO_PROFILE        使うプロファイル (--profile)
+  ZGYAZO_CLIENT_ID      OAuth アプリケーションの Client ID (config の oauth.client_id)
+  ZGYAZO_CLIENT_SECRET  OAuth アプリケーションの Client Secret (config の oauth.client_secret)
+
+設定の優先順位は コマンドラインフラグ > 環境変数 > 設定ファイル > デフォルト値
+`
+
+// globalOptions はサブコマンドの前に指定するフラグ
+type globalOptions struct {
+	configPath string
+	logLevel   string
+	logFormat  string
+	endpoint   string
+	watch      string
+	profile    string
+}
+
+// parseGlobalOptions はグローバルフラグを解析し、残りの引数 (サブコマンドとその引数) を返す
+func parseGlobalOptions(args []string) (*globalOptions, []string, error) {
+	g := &globalOptions{}
+	fs := flag.NewFlagSet("zgyazo", flag.ContinueOnError)
+	fs.StringVar(&g.configPath, "config", "", "設定ファイルのパス (デフォルト: "+config.DefaultPath()+")")
+	fs.StringVar(&g.logLevel, "log-level", "", "ログレベル (debug, info, warn, error)")
+	fs.StringVar(&g.logFormat, "log-format", "", "ログの形式 (text, json)")
+	fs.StringVar(&g.endpoint, "endpoint", "", "Gyazo API のエンドポイント (Gyazo 互換サーバーを使う場合)")
+	fs.StringVar(&g.profile, "profile", "", "使うプロファイル (config の profile)")
+	fs.StringVar(&g.watch, "watch", "", "監視するディレクトリ (config の watches を置き換える)")
+	fs.Usage = func() {
+		var flags strings.Builder
+		fs.SetOutput(&flags)
+		fs.PrintDefaults()
+		fs.SetOutput(os.Stderr)
+		fmt.Fprintf(os.Stderr, usage, flags.String())
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+	return g, fs.Args(), nil
+}
+
+// resolvedConfigPath は使用する設定ファイルのパスを返す
+func (g *globalOptions) resolvedConfigPath() string {
+	return firstNonEmpty(g.configPath, os.Getenv(envConfig), config.DefaultPath())
+}
+
+// loadConfig は設定ファイルを読み込み、使うプロファイルを反映して環境変数とフラグで上書きする
+func (g *globalOptions) loadConfig() (*config.Config, error) {
+	cfg, err := config.Load(g.resolvedConfigPath())
+	if err != nil {
+		return nil, err
+	}
+	return g.withProfile(cfg, firstNonEmpty(g.profile, os.Getenv(envProfile), cfg.Profile))
+}
+
+// withProfile は cfg に name のプロファイルを反映し、環境変数とフラグで上書きした設定を返す
+// 環境変数とフラグはどのプロファイルよりも優先する
+func (g *globalOptions) withProfile(cfg *config.Config, name string) (*config.Config, error) {
+	cfg, err := cfg.WithProfile(name)
+	if err != nil {
+		return nil, err
+	}
+	g.applyOverrides(cfg)
+	return cfg, nil
+}
+
+// applyOverrides は cfg を環境変数とフラグで上書きする
+func (g *globalOptions) applyOverrides(cfg *config.Config) {
+	cfg.GyazoAccessToken = firstNonEmpty(os.Getenv(envToken), cfg.GyazoAccessToken)
+	cfg.LogLevel = firstNonEmpty(g.logLevel, os.Getenv(envLogLevel), cfg.LogLevel)
+	cfg.LogFormat = firstNonEmpty(g.logFormat, os.Getenv(envLogFormat), cfg.LogFormat)
+	cfg.Endpoint = firstNonEmpty(g.endpoint, os.Getenv(envEndpoint), cfg.Endpoint)
+	// 監視するディレクトリを指定した場合は、設定ファイルの watches をすべて置き換える
+	if watch := firstNonEmpty(g.watch, os.Getenv(envWatch)); watch != "" {
+		cfg.Watches = []config.Watch{{Path: watch}}
+	}
+}
+
+// setupCommandLogger はサブコマンドのログの出力先を設定する
+// ログレベルが明示的に指定された場合だけ標準エラー出力に出力する
+func (g *globalOptions) setupCommandLogger() error {
+	opts, err := g.commandLogOptions()
+	if err != nil {
+		return err
+	}
+	if firstNonEmpty(g.logLevel, os.Getenv(envLogLevel)) == "" {
+		logging.Discard()
+		return nil
+	}
+	logging.Configure(os.Stderr, opts)
+	return nil
+}
+
+// commandLogOptions はフラグと環境変数で指定したログの出力方法を返す
+// 設定ファイルを読み込む前のログに使う
+func (g *globalOptions) commandLogOptions() (logging.Options, error) {
+	cfg := &config.Config{
+		LogLevel:  firstNonEmpty(g.logLevel, os.Getenv(envLogLevel)),
+		LogFormat: firstNonEmpty(g.logFormat, os.Getenv(envLogFormat)),
+	}
+	return cfg.LogOptions()
+}
+
+// newGyazoClient は設定に従って Gyazo API クライアントを生成する
+func newGyazoClient(cfg *config.Config) (*gyazo.Client, error) {
+	opts := []gyazo.Option{gyazo.WithLogger(logging.Logger(logging.ComponentGyazo))}
+	if cfg.Endpoint != "" {
+		opts = append(opts, gyazo.WithUploadEndpoint(cfg.Endpoint), gyazo.WithAPIEndpoint(cfg.Endpoint))
+	}
+	return gyazo.NewClient(cfg.GyazoAccessToken, opts...)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// versionString はバージョンを返す
+// -ldflags で設定されていない場合は go install 時のモジュールのバージョンを使う
+func versionString() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
+
+// runVersionCommand は zgyazo version を実行する
+func runVersionCommand(g *globalOptions, args []string) int {
+	fmt.Printf("zgyazo %s\n", versionString())
+	return 0
+}
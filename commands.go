@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/zztkm/zgyazo/gyazo"
+	"github.com/zztkm/zgyazo/internal/config"
+	"github.com/zztkm/zgyazo/internal/history"
+)
+
+// commandEnv はサブコマンドが使う設定・クライアント・履歴
+type commandEnv struct {
+	config  *config.Config
+	client  *gyazo.Client
+	history *history.Store
+}
+
+// loadCommandEnv は設定ファイルを読み込み、サブコマンドの実行に必要なものを用意する
+func loadCommandEnv(g *globalOptions) (*commandEnv, error) {
+	cfg, err := g.loadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	client, err := newGyazoClient(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Gyazo client: %w", err)
+	}
+	hist, err := history.Open(history.DefaultPath())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history: %w", err)
+	}
+	return &commandEnv{config: cfg, client: client, history: hist}, nil
+}
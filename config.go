@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/zztkm/zgyazo/internal/config"
+)
+
+// runConfigCommand は zgyazo config <subcommand> を実行し、終了コードを返す
+func runConfigCommand(g *globalOptions, args []string) int {
+	usage := func() {
+		fmt.Fprintln(os.Stderr, "Usage: zgyazo config <command>")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Commands:")
+		fmt.Fprintln(os.Stderr, "  init      対話的に設定ファイルを作成する")
+		fmt.Fprintln(os.Stderr, "  path      設定ファイルのパスを表示する")
+		fmt.Fprintln(os.Stderr, "  show      環境変数とフラグを反映した設定を表示する (トークンは伏せる)")
+		fmt.Fprintln(os.Stderr, "  validate  設定に問題がないかを確認する")
+		fmt.Fprintln(os.Stderr, "  schema    設定ファイルの JSON Schema を表示する")
+	}
+	if len(args) == 0 {
+		usage()
+		return 2
+	}
+	if err := g.setupCommandLogger(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	if args[0] == "init" {
+		return initConfig(g, args[1:])
+	}
+	if len(args) != 1 {
+		usage()
+		return 2
+	}
+
+	switch args[0] {
+	case "path":
+		fmt.Println(g.resolvedConfigPath())
+		return 0
+	case "show":
+		cfg, err := g.loadConfig()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+			return 1
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(cfg.Masked()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	case "validate":
+		return validateConfig(g)
+	case "schema":
+		os.Stdout.Write(config.Schema)
+		return 0
+	default:
+		usage()
+		return 2
+	}
+}
+
+// validateConfig は zgyazo config validate を実行する
+// 環境変数とフラグを反映した設定を検証し、問題をすべて表示する
+func validateConfig(g *globalOptions) int {
+	path := g.resolvedConfigPath()
+	cfg, err := g.loadConfig()
+	if err == nil {
+		err = cfg.Validate(path)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("%s: OK\n", path)
+	return 0
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+
+	"github.com/zztkm/zgyazo/internal/config"
+	"github.com/zztkm/zgyazo/internal/platform"
+	"github.com/zztkm/zgyazo/internal/secret"
+)
+
+// keystoreTokenName は config init でトークンをキーストアに保存するときの名前
+const keystoreTokenName = "gyazo"
+
+// initConfig は zgyazo config init を実行する
+// 対話的にトークンとキャプチャの保存先を尋ね、設定ファイルを作成する
+func initConfig(g *globalOptions, args []string) int {
+	fs := flag.NewFlagSet("config init", flag.ContinueOnError)
+	force := fs.Bool("force", false, "既存の設定ファイルを上書きする")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: zgyazo config init [-force]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	path := g.resolvedConfigPath()
+	if info, err := os.Stat(path); err == nil && info.Size() > 0 && !*force {
+		// 以前のバージョンが作成した空の設定ファイルは上書きしてよい
+		fmt.Fprintf(os.Stderr, "%s already exists; use -force to overwrite it\n", path)
+		return 1
+	}
+
+	in := bufio.NewReader(os.Stdin)
+
+	// トークン
+	fmt.Println("Gyazo API のアクセストークンを入力してください (https://gyazo.com/api で発行できます)")
+	token, err := readSecret(in, "Access token: ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if token == "" {
+		fmt.Fprintln(os.Stderr, "access token must not be empty")
+		return 1
+	}
+
+	cfg := &config.Config{
+		Schema:           config.SchemaURL,
+		Version:          config.CurrentVersion,
+		GyazoAccessToken: token,
+		OutputFormat:     config.OutputFormatURL,
+		LogLevel:         "info",
+		Endpoint:         firstNonEmpty(g.endpoint, os.Getenv(envEndpoint)),
+	}
+
+	// トークンが有効か確認する
+	client, err := newGyazoClient(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	user, err := client.Me(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to verify the access token: %v\n", err)
+		return 1
+	}
+	fmt.Printf("%s としてログインできました\n", user.Name)
+
+	// 設定ファイルを同期やコミットしてもトークンが漏れないよう、キーストアに保存できるようにする
+	if confirm(in, "トークンを設定ファイルではなく暗号化したキーストアに保存しますか? [y/N]: ") {
+		if err := secret.DefaultKeystore().Set(keystoreTokenName, token); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to save the token to the keystore: %v\n", err)
+			return 1
+		}
+		cfg.GyazoAccessToken = secret.KeystoreReference(keystoreTokenName)
+	}
+
+	// キャプチャの保存先
+	detected := firstNonEmpty(g.watch, os.Getenv(envWatch), detectCaptureDir())
+	prompt := "キャプチャツールが画像を保存するフォルダ: "
+	if detected != "" {
+		prompt = fmt.Sprintf("キャプチャツールが画像を保存するフォルダ [%s]: ", detected)
+	}
+	dir, err := readLine(in, prompt)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	dir = firstNonEmpty(dir, detected)
+	if dir == "" {
+		fmt.Fprintln(os.Stderr, "capture folder must not be empty")
+		return 1
+	}
+	cfg.Watches = []config.Watch{{Path: dir}}
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if !confirm(in, fmt.Sprintf("%s does not exist. Create it? [y/N]: ", dir)) {
+			fmt.Println("Canceled")
+			return 1
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	if err := cfg.Validate(path); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := cfg.Save(path); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write config: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Wrote %s\n", path)
+	return 0
+}
+
+// detectCaptureDir はキャプチャの保存先の候補のうち、存在する最初のディレクトリを返す
+func detectCaptureDir() string {
+	for _, dir := range platform.CaptureDirCandidates() {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir
+		}
+	}
+	return ""
+}
+
+// readLine は prompt を表示して 1 行読み込む
+func readLine(in *bufio.Reader, prompt string) (string, error) {
+	fmt.Print(prompt)
+	line, err := in.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// readSecret は prompt を表示し、端末の場合は入力を表示せずに 1 行読み込む
+// 端末でない場合 (パイプなど) は通常どおり読み込む
+func readSecret(in *bufio.Reader, prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return readLine(in, prompt)
+	}
+	fmt.Print(prompt)
+	secret, err := term.ReadPassword(fd)
+	fmt.Println()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(secret)), nil
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/zztkm/zgyazo/internal/history"
+	"github.com/zztkm/zgyazo/internal/instance"
+)
+
+// 常駐プロセスが受け付ける要求の名前
+const (
+	ctlUpload  = "upload"
+	ctlDelete  = "delete"
+	ctlEnqueue = "enqueue"
+	ctlStatus  = "status"
+	ctlPause   = "pause"
+	ctlResume  = "resume"
+	ctlLast    = "last"
+	ctlHistory = "history"
+)
+
+// ctlTimeout は zgyazo ctl が応答を待つ時間
+const ctlTimeout = 10 * time.Second
+
+// defaultCtlHistoryLimit は history で返す履歴の件数の省略時の値
+const defaultCtlHistoryLimit = 10
+
+// ctlEnqueueParams は enqueue の引数
+type ctlEnqueueParams struct {
+	// Paths はキューに追加する画像の絶対パス
+	Paths []string `json:"paths"`
+}
+
+// ctlEnqueueResult は enqueue の 1 ファイル分の結果
+type ctlEnqueueResult struct {
+	Path  string `json:"path"`
+	Error string `json:"error,omitempty"`
+}
+
+// ctlPauseParams は pause の引数
+type ctlPauseParams struct {
+	// Duration は自動で再開するまでの時間 ("30m" など)
+	// 空の場合は設定ファイルの pause_auto_resume に従う
+	Duration string `json:"duration,omitempty"`
+}
+
+// ctlHistoryParams は history の引数
+type ctlHistoryParams struct {
+	// Limit は返す履歴の件数 (0 の場合は defaultCtlHistoryLimit)
+	Limit int `json:"limit,omitempty"`
+}
+
+// startControlServer は zgyazo ctl や zgyazo upload からの要求を常駐プロセスで処理するサーバーを起動する
+func startControlServer(d *daemonAPI) (*instance.Server, error) {
+	server := instance.NewServer(instance.Address())
+	server.Handle(ctlUpload, func(ctx context.Context, params json.RawMessage) (any, error) {
+		var req forwardUploadRequest
+		if err := json.Unmarshal(params, &req); err != nil {
+			return nil, err
+		}
+		return d.reloader.forwardedUpload(ctx, d.history, req)
+	})
+	server.Handle(ctlDelete, func(ctx context.Context, params json.RawMessage) (any, error) {
+		var req forwardDeleteRequest
+		if err := json.Unmarshal(params, &req); err != nil {
+			return nil, err
+		}
+		return d.reloader.forwardedDelete(ctx, d.history, req)
+	})
+	server.Handle(ctlEnqueue, func(ctx context.Context, params json.RawMessage) (any, error) {
+		var req ctlEnqueueParams
+		if err := json.Unmarshal(params, &req); err != nil {
+			return nil, err
+		}
+		results := make([]ctlEnqueueResult, 0, len(req.Paths))
+		for _, path := range req.Paths {
+			res := ctlEnqueueResult{Path: path}
+			// 常駐プロセスとクライアントで作業ディレクトリが違うため、相対パスは受け付けない
+			if !filepath.IsAbs(path) {
+				res.Error = "path must be absolute"
+			} else if err := d.Enqueue(filepath.Clean(path)); err != nil {
+				res.Error = err.Error()
+			}
+			results = append(results, res)
+		}
+		return results, nil
+	})
+	server.Handle(ctlStatus, func(ctx context.Context, params json.RawMessage) (any, error) {
+		return d.Status(), nil
+	})
+	server.Handle(ctlPause, func(ctx context.Context, params json.RawMessage) (any, error) {
+		var req ctlPauseParams
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &req); err != nil {
+				return nil, err
+			}
+		}
+		var after time.Duration
+		if req.Duration != "" {
+			var err error
+			if after, err = parsePauseDuration(req.Duration); err != nil {
+				return nil, err
+			}
+		}
+		d.Pause(after)
+		return d.Status(), nil
+	})
+	server.Handle(ctlResume, func(ctx context.Context, params json.RawMessage) (any, error) {
+		d.Resume()
+		return d.Status(), nil
+	})
+	server.Handle(ctlLast, func(ctx context.Context, params json.RawMessage) (any, error) {
+		entry, ok := d.history.Last()
+		if !ok {
+			return nil, errors.New("no uploads yet")
+		}
+		return entry, nil
+	})
+	server.Handle(ctlHistory, func(ctx context.Context, params json.RawMessage) (any, error) {
+		var req ctlHistoryParams
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &req); err != nil {
+				return nil, err
+			}
+		}
+		if req.Limit <= 0 {
+			req.Limit = defaultCtlHistoryLimit
+		}
+		return recentHistory(d.history, req.Limit), nil
+	})
+	if err := server.Start(); err != nil {
+		return nil, err
+	}
+	return server, nil
+}
+
+// parsePauseDuration は一時停止してから自動で再開するまでの時間を読み取る
+func parsePauseDuration(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("duration must be a positive duration such as 30m: %q", s)
+	}
+	return d, nil
+}
+
+// recentHistory は新しい順に最大 n 件の履歴を返す
+func recentHistory(hist *history.Store, n int) []history.Entry {
+	entries := hist.Entries()
+	recent := entries[max(0, len(entries)-n):]
+	slices.Reverse(recent)
+	return recent
+}
+
+// runCtlCommand は zgyazo ctl を実行し、終了コードを返す
+// 常駐プロセスに要求を送り、結果を JSON で出力する
+func runCtlCommand(g *globalOptions, args []string) int {
+	fs := flag.NewFlagSet("ctl", flag.ContinueOnError)
+	limit := fs.Int("n", defaultCtlHistoryLimit, "history で表示する件数")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: zgyazo ctl [-n count] <method> [args]")
+		fmt.Fprintln(fs.Output(), "")
+		fmt.Fprintln(fs.Output(), "起動中の常駐プロセスに要求を送り、結果を JSON で表示する")
+		fmt.Fprintln(fs.Output(), "")
+		fmt.Fprintln(fs.Output(), "Methods:")
+		fmt.Fprintln(fs.Output(), "  status            キューやアップロード中の画像などの状態")
+		fmt.Fprintln(fs.Output(), "  pause [duration]  アップロードを一時停止する (30m などを指定すると自動で再開する)")
+		fmt.Fprintln(fs.Output(), "  resume            一時停止を解除する")
+		fmt.Fprintln(fs.Output(), "  enqueue <file...> 画像をアップロードのキューに追加する")
+		fmt.Fprintln(fs.Output(), "  last              直前にアップロードした画像")
+		fmt.Fprintln(fs.Output(), "  history           最近アップロードした画像 (新しい順)")
+		fmt.Fprintln(fs.Output(), "")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	if err := g.setupCommandLogger(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	method, rest := fs.Arg(0), fs.Args()[1:]
+	var params any
+	switch method {
+	case ctlPause:
+		if len(rest) > 1 {
+			fmt.Fprintf(os.Stderr, "Unexpected arguments for %s: %v\n", method, rest[1:])
+			return 2
+		}
+		if len(rest) == 1 {
+			if _, err := parsePauseDuration(rest[0]); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 2
+			}
+			params = ctlPauseParams{Duration: rest[0]}
+		}
+	case ctlStatus, ctlResume, ctlLast:
+		if len(rest) > 0 {
+			fmt.Fprintf(os.Stderr, "Unexpected arguments for %s: %v\n", method, rest)
+			return 2
+		}
+	case ctlHistory:
+		if len(rest) > 0 {
+			fmt.Fprintf(os.Stderr, "Unexpected arguments for %s: %v\n", method, rest)
+			return 2
+		}
+		params = ctlHistoryParams{Limit: *limit}
+	case ctlEnqueue:
+		if len(rest) == 0 {
+			fmt.Fprintln(os.Stderr, "Usage: zgyazo ctl enqueue <file...>")
+			return 2
+		}
+		req := ctlEnqueueParams{}
+		for _, file := range rest {
+			abs, err := filepath.Abs(file)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+			req.Paths = append(req.Paths, abs)
+		}
+		params = req
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown method: %s\n", method)
+		fs.Usage()
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ctlTimeout)
+	defer cancel()
+	var result json.RawMessage
+	if err := instance.Call(ctx, method, params, &result); err != nil {
+		if errors.Is(err, instance.ErrNotRunning) {
+			fmt.Fprintln(os.Stderr, "zgyazo is not running")
+			return 1
+		}
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, result, "", "  "); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	out.WriteByte('\n')
+	if _, err := out.WriteTo(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	// enqueue は 1 つでも追加できなかった場合に失敗とする
+	if method == ctlEnqueue {
+		var results []ctlEnqueueResult
+		json.Unmarshal(result, &results)
+		for _, r := range results {
+			if r.Error != "" {
+				return 1
+			}
+		}
+	}
+	return 0
+}
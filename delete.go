@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zztkm/zgyazo/gyazo"
+	"github.com/zztkm/zgyazo/internal/history"
+	"github.com/zztkm/zgyazo/internal/platform"
+	"github.com/zztkm/zgyazo/internal/uploader"
+)
+
+// undoConfirmWindow は取り消しのホットキーを 2 回押して削除を確定するまでの猶予
+const undoConfirmWindow = 5 * time.Second
+
+// deleteImage は imageID の画像を Gyazo から削除し、履歴を削除済みにする
+func deleteImage(ctx context.Context, client *gyazo.Client, hist *history.Store, imageID string) error {
+	slog.Info("deleting image", "image_id", imageID)
+	if _, err := client.DeleteImage(ctx, imageID); err != nil {
+		return err
+	}
+	if err := hist.MarkDeleted(imageID, time.Now()); err != nil {
+		// Gyazo からは削除できているので、履歴の更新失敗はログに残すだけにする
+		slog.Error("failed to update history", "image_id", imageID, "error", err)
+	}
+	slog.Info("deleted image", "image_id", imageID)
+	return nil
+}
+
+// runDeleteCommand は zgyazo delete <image_id|url> を実行し、終了コードを返す
+func runDeleteCommand(g *globalOptions, args []string) int {
+	fs := flag.NewFlagSet("delete", flag.ContinueOnError)
+	yes := fs.Bool("y", false, "確認せずに削除する")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: zgyazo delete [-y] <image_id|url>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	if err := g.setupCommandLogger(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	imageID, err := gyazo.ParseImageID(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	env, err := loadCommandEnv(g)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	target := imageID
+	client := env.client
+	if entry, ok := env.history.Find(imageID); ok {
+		target = fmt.Sprintf("%s (%s, uploaded at %s)", entry.PermalinkURL, entry.FilePath, entry.UploadedAt.Format(time.DateTime))
+		// 別のプロファイルでアップロードした画像は、そのプロファイルのアカウントでないと削除できない
+		if entry.Profile != env.config.Profile {
+			profileConfig, err := g.withProfile(env.config, entry.Profile)
+			if err == nil {
+				client, err = newGyazoClient(profileConfig)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to use profile %q that uploaded %s: %v\n", entry.Profile, imageID, err)
+				return 1
+			}
+		}
+	}
+	if !*yes && !confirm(bufio.NewReader(os.Stdin), fmt.Sprintf("Delete %s? [y/N]: ", target)) {
+		fmt.Println("Canceled")
+		return 1
+	}
+
+	// 常駐プロセスが起動している場合は、履歴を上書きされないように常駐プロセスで削除する
+	deleted, err := forwardDelete(g, env, imageID)
+	if !deleted {
+		err = deleteImage(context.Background(), client, env.history, imageID)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to delete %s: %v\n", imageID, err)
+		return 1
+	}
+	fmt.Printf("Deleted %s\n", imageID)
+	return 0
+}
+
+// confirm は prompt を表示し、y または yes が入力されたら true を返す
+func confirm(in *bufio.Reader, prompt string) bool {
+	fmt.Print(prompt)
+	answer, _ := in.ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// undoer は直前のアップロードを取り消すホットキーの処理
+// 誤操作で削除しないよう、1 回目の押下では対象を通知するだけにして
+// undoConfirmWindow 以内にもう一度押されたときに削除する
+type undoer struct {
+	// accountFor はアップロードしたプロファイルのアカウントを返す
+	accountFor func(profile string) (*uploader.Account, error)
+
+	history  *history.Store
+	notifier platform.Notifier
+	keys     string
+
+	mu        sync.Mutex
+	pendingID string
+	deadline  time.Time
+}
+
+// undoLast は取り消しのホットキーが押されたときに呼び出される
+func (u *undoer) undoLast() {
+	entry, ok := u.history.Last()
+	if !ok {
+		u.notify("取り消せるアップロードがありません", "")
+		return
+	}
+
+	u.mu.Lock()
+	keys := u.keys
+	confirmed := u.pendingID == entry.ImageID && time.Now().Before(u.deadline)
+	if confirmed {
+		u.pendingID = ""
+	} else {
+		u.pendingID = entry.ImageID
+		u.deadline = time.Now().Add(undoConfirmWindow)
+	}
+	u.mu.Unlock()
+
+	if !confirmed {
+		u.notify(
+			fmt.Sprintf("%d 秒以内にもう一度 %s を押すと削除します", int(undoConfirmWindow.Seconds()), keys),
+			entry.PermalinkURL,
+		)
+		return
+	}
+
+	// ホットキーのメッセージループを止めないように削除は別の goroutine で行う
+	go func() {
+		// 監視ディレクトリごとのプロファイルでアップロードした画像は、そのアカウントで削除する
+		account, err := u.accountFor(entry.Profile)
+		if err != nil {
+			slog.Error("failed to get the account that uploaded the image", "image_id", entry.ImageID, "profile", entry.Profile, "error", err)
+			u.notify("削除に失敗しました", entry.PermalinkURL)
+			return
+		}
+		if err := deleteImage(context.Background(), account.Client, u.history, entry.ImageID); err != nil {
+			slog.Error("failed to delete image", "image_id", entry.ImageID, "error", err)
+			u.notify("削除に失敗しました", entry.PermalinkURL)
+			return
+		}
+		u.notify("アップロードを取り消しました", entry.PermalinkURL)
+	}()
+}
+
+// setKeys は通知に表示する取り消しのホットキーを変更する
+func (u *undoer) setKeys(keys string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.keys = keys
+}
+
+func (u *undoer) notify(title string, message string) {
+	if err := u.notifier.Notify(title, message); err != nil {
+		slog.Warn("failed to notify", "error", err)
+	}
+}
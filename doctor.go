@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/zztkm/zgyazo/gyazo"
+	"github.com/zztkm/zgyazo/internal/api"
+	"github.com/zztkm/zgyazo/internal/config"
+	"github.com/zztkm/zgyazo/internal/deadletter"
+	"github.com/zztkm/zgyazo/internal/instance"
+	"github.com/zztkm/zgyazo/internal/platform"
+	"github.com/zztkm/zgyazo/internal/queue"
+)
+
+// 診断結果の状態
+const (
+	checkOK   = "ok"
+	checkWarn = "warn"
+	checkFail = "fail"
+	checkSkip = "skip"
+)
+
+// certExpiryWarning は TLS 証明書の有効期限が近いと警告するまでの残り期間
+const certExpiryWarning = 14 * 24 * time.Hour
+
+// doctorCheck は診断 1 件の結果
+type doctorCheck struct {
+	Name string `json:"name"`
+
+	// Target は確認したファイルや URL など (ない場合は空)
+	Target string `json:"target,omitempty"`
+
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// doctorReport は zgyazo doctor -json の出力
+type doctorReport struct {
+	OK      bool          `json:"ok"`
+	Version string        `json:"version"`
+	Checks  []doctorCheck `json:"checks"`
+}
+
+// reportFunc は診断結果 1 件を報告する関数
+type reportFunc func(name, target, status, format string, args ...any)
+
+// runDoctorCommand は zgyazo doctor を実行し、終了コードを返す
+// 問題が見つかった場合は 1 を返す。警告だけの場合は 0 を返す
+func runDoctorCommand(g *globalOptions, args []string) int {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	jsonOutput := fs.Bool("json", false, "結果を JSON で出力する")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: zgyazo doctor [-json]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+	if err := g.setupCommandLogger(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	report := &doctorReport{OK: true, Version: versionString()}
+	add := func(name, target, status, format string, args ...any) {
+		if status == checkFail {
+			report.OK = false
+		}
+		report.Checks = append(report.Checks, doctorCheck{Name: name, Target: target, Status: status, Message: fmt.Sprintf(format, args...)})
+	}
+	runDoctorChecks(g, add)
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	} else {
+		labels := map[string]string{checkOK: " OK ", checkWarn: "WARN", checkFail: "FAIL", checkSkip: "SKIP"}
+		for _, c := range report.Checks {
+			line := fmt.Sprintf("[%s] %s", labels[c.Status], c.Name)
+			if c.Target != "" {
+				line += " " + c.Target
+			}
+			if c.Message != "" {
+				line += ": " + c.Message
+			}
+			fmt.Println(line)
+		}
+	}
+	if !report.OK {
+		return 1
+	}
+	return 0
+}
+
+// runDoctorChecks はすべての診断を実行し、結果を add に渡す
+// 設定ファイルを読み込めない場合も、設定に依存しない診断は続ける
+func runDoctorChecks(g *globalOptions, add reportFunc) {
+	check := func(name, target string, err error) {
+		if err != nil {
+			add(name, target, checkFail, "%v", err)
+		} else {
+			add(name, target, checkOK, "")
+		}
+	}
+
+	configPath := g.resolvedConfigPath()
+	cfg, err := g.loadConfig()
+	check("config", configPath, err)
+	if err == nil {
+		check("config validation", configPath, cfg.Validate(configPath))
+		checkToken(g, cfg, add)
+		for _, endpoint := range endpointsOf(cfg) {
+			checkEndpoint(endpoint, add)
+		}
+		checkWatches(cfg, add)
+		checkHotkeys(cfg, add)
+	}
+
+	checkLogDir(add)
+	checkQueue(cfg, add)
+}
+
+// checkToken はアクセストークンでユーザー情報を取得できるかを確認する
+// 常駐プロセスと同じく、監視ディレクトリごとのプロファイルのアカウントもすべて確認する
+func checkToken(g *globalOptions, cfg *config.Config, add reportFunc) {
+	account, err := newAccount(cfg)
+	if err != nil {
+		add("access token", cfg.Profile, checkFail, "%v", err)
+		return
+	}
+	watches, err := g.uploaderWatches(cfg)
+	if err != nil {
+		add("access token", "", checkFail, "%v", err)
+	}
+	for _, account := range accountsOf(account, watches) {
+		user, err := verifyAccount(account)
+		if err != nil {
+			add("access token", account.Profile, checkFail, "%v", tokenError(account, err))
+			continue
+		}
+		add("access token", account.Profile, checkOK, "logged in as %s", describeAccount(user))
+	}
+}
+
+// endpointsOf は cfg で使う API のエンドポイントを返す
+func endpointsOf(cfg *config.Config) []string {
+	if cfg.Endpoint != "" {
+		return []string{cfg.Endpoint}
+	}
+	return []string{gyazo.DefaultAPIEndpoint, gyazo.DefaultUploadEndpoint}
+}
+
+// checkEndpoint はエンドポイントに接続でき、TLS 証明書が有効かを確認する
+// HTTP のレスポンスが返ればステータスコードは問わない
+func checkEndpoint(endpoint string, add reportFunc) {
+	client := &http.Client{
+		Timeout: tokenCheckTimeout,
+		// リダイレクト先ではなくエンドポイント自体を確認する
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Head(endpoint)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		add("endpoint", endpoint, checkFail, "request failed: %v", err)
+		return
+	}
+	resp.Body.Close()
+
+	if resp.TLS == nil {
+		add("endpoint", endpoint, checkWarn, "reachable (HTTP %d) but not using TLS; the access token is sent in plain text", resp.StatusCode)
+		return
+	}
+	cert := resp.TLS.PeerCertificates[0]
+	detail := fmt.Sprintf("reachable (HTTP %d, %s, certificate valid until %s)",
+		resp.StatusCode, tls.VersionName(resp.TLS.Version), cert.NotAfter.Format(time.DateOnly))
+	if time.Until(cert.NotAfter) < certExpiryWarning {
+		add("endpoint", endpoint, checkWarn, "%s; the certificate expires soon", detail)
+		return
+	}
+	add("endpoint", endpoint, checkOK, "%s", detail)
+}
+
+// checkWatches は監視するディレクトリが読めることと、fsnotify で監視できることを確認する
+func checkWatches(cfg *config.Config, add reportFunc) {
+	for _, w := range cfg.Watches {
+		entries, err := os.ReadDir(w.Path)
+		if err != nil {
+			add("watch", w.Path, checkFail, "%v", err)
+			continue
+		}
+		add("watch", w.Path, checkOK, "readable, %d entries", len(entries))
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		add("file watcher", "", checkFail, "%v", err)
+		return
+	}
+	defer watcher.Close()
+	for _, path := range cfg.WatchPaths() {
+		if err := watcher.Add(path); err != nil {
+			add("file watcher", path, checkFail, "%v", err)
+			return
+		}
+	}
+	add("file watcher", "", checkOK, "")
+}
+
+// checkHotkeys はホットキーを登録できるかを確認する
+func checkHotkeys(cfg *config.Config, add reportFunc) {
+	keys := []string{cfg.Hotkeys.CaptureKeys(), cfg.Hotkeys.UndoKeys()}
+	if len(cfg.Profiles) > 0 {
+		keys = append(keys, cfg.Hotkeys.SwitchProfileKeys())
+	}
+	if pause := cfg.Hotkeys.PauseKeys(); pause != "" {
+		keys = append(keys, pause)
+	}
+	for _, k := range keys {
+		err := platform.CheckHotkey(k)
+		switch {
+		case errors.Is(err, platform.ErrHotkeysUnsupported):
+			add("hotkey", k, checkSkip, "%v", err)
+		case err != nil:
+			add("hotkey", k, checkFail, "%v (already used by another app, or by zgyazo itself if it is running)", err)
+		default:
+			add("hotkey", k, checkOK, "available")
+		}
+	}
+}
+
+// checkLogDir はログファイルのディレクトリに書き込めるかを確認する
+func checkLogDir(add reportFunc) {
+	if err := os.MkdirAll(platform.StateDir(), 0755); err != nil {
+		add("log directory", platform.StateDir(), checkFail, "%v", err)
+		return
+	}
+	f, err := os.CreateTemp(platform.StateDir(), "doctor-*.tmp")
+	if err != nil {
+		add("log directory", platform.StateDir(), checkFail, "not writable: %v", err)
+		return
+	}
+	f.Close()
+	os.Remove(f.Name())
+	if info, err := os.Stat(platform.LogPath()); err == nil {
+		add("log directory", platform.StateDir(), checkOK, "writable, log file is %d KB", info.Size()/1024)
+		return
+	}
+	add("log directory", platform.StateDir(), checkOK, "writable")
+}
+
+// checkQueue はアップロードを待っている画像と、アップロードできなかった画像の件数を確認する
+// cfg が nil の場合 (設定ファイルを読み込めない場合) は、アップロードできなかった画像だけ確認する
+func checkQueue(cfg *config.Config, add reportFunc) {
+	checkDaemonQueue(cfg, add)
+
+	// 一時停止中や終了時にアップロードし終えていなかった画像は、次の起動時にアップロードする
+	pending, err := queue.Open(queue.DefaultPath())
+	if err != nil {
+		add("saved queue", queue.DefaultPath(), checkFail, "%v", err)
+	} else if n := pending.Len(); n > 0 {
+		add("saved queue", queue.DefaultPath(), checkOK, "%d file(s) not uploaded yet", n)
+	} else {
+		add("saved queue", queue.DefaultPath(), checkOK, "empty")
+	}
+
+	dead, err := deadletter.Open(deadletter.DefaultPath())
+	if err != nil {
+		add("dead letters", deadletter.DefaultPath(), checkFail, "%v", err)
+		return
+	}
+	if n := dead.Len(); n > 0 {
+		add("dead letters", deadletter.DefaultPath(), checkWarn, "%d upload(s) failed after all retries", n)
+		return
+	}
+	add("dead letters", deadletter.DefaultPath(), checkOK, "none")
+}
+
+// checkDaemonQueue は常駐プロセスでアップロードを待っている画像の件数を確認する
+// キューは常駐プロセスのメモリ上にしかないため、常駐プロセスに問い合わせる
+func checkDaemonQueue(cfg *config.Config, add reportFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), tokenCheckTimeout)
+	defer cancel()
+	status, err := daemonStatus(ctx, cfg)
+	if errors.Is(err, instance.ErrNotRunning) {
+		add("upload queue", "", checkSkip, "zgyazo is not running")
+		return
+	}
+	if err != nil {
+		add("upload queue", "", checkSkip, "cannot get the status of the running zgyazo: %v", err)
+		return
+	}
+	result := checkOK
+	if len(status.PauseReasons) > 0 || status.WatcherError != "" {
+		result = checkWarn
+	}
+	msg := fmt.Sprintf("%d queued, %d uploading, %d waiting to retry", status.QueueLength+len(status.Waiting)+len(status.Held), len(status.InFlight), len(status.Retries))
+	if len(status.PauseReasons) > 0 {
+		msg += fmt.Sprintf("; paused (%v)", status.PauseReasons)
+	}
+	if status.WatcherError != "" {
+		msg += "; watcher error: " + status.WatcherError
+	}
+	add("upload queue", fmt.Sprintf("pid %d", status.PID), result, "%s", msg)
+}
+
+// daemonStatus は常駐プロセスの状態を取得する
+// zgyazo ctl と同じくソケットや名前付きパイプで問い合わせ、接続できない場合は API を有効にしていれば API で問い合わせる
+func daemonStatus(ctx context.Context, cfg *config.Config) (*api.Status, error) {
+	var status api.Status
+	err := instance.Call(ctx, ctlStatus, nil, &status)
+	if err == nil {
+		return &status, nil
+	}
+	if !errors.Is(err, instance.ErrNotRunning) || cfg == nil || cfg.API == nil {
+		return nil, err
+	}
+	token, tokenErr := api.ReadToken(api.TokenPath())
+	if tokenErr != nil {
+		// API のトークンもない場合は、常駐プロセスが起動していないものとして扱う
+		return nil, err
+	}
+	return api.NewClient(cfg.API.ListenPort(), token).Status(ctx)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/zztkm/zgyazo/gyazo"
+	"github.com/zztkm/zgyazo/internal/history"
+	"github.com/zztkm/zgyazo/internal/instance"
+	"github.com/zztkm/zgyazo/internal/uploader"
+)
+
+// forwardUploadRequest は zgyazo upload を常駐プロセスに転送するときの要求
+type forwardUploadRequest struct {
+	// ConfigPath と Profile は、常駐プロセスと同じ設定とプロファイルでアップロードするかの確認に使う
+	ConfigPath string `json:"config_path"`
+	Profile    string `json:"profile,omitempty"`
+
+	// Files はアップロードするファイルの絶対パス
+	Files []string `json:"files"`
+
+	Desc         string `json:"desc,omitempty"`
+	AccessPolicy string `json:"access_policy"`
+}
+
+// forwardUpload は起動中の常駐プロセスに files のアップロードを依頼する
+// 常駐プロセスが起動していない場合や、転送できない引数の場合は ok に false を返す
+// 別のプロセスが履歴を書き換えると常駐プロセスの履歴と食い違うため、起動中は常駐プロセスでアップロードする
+func forwardUpload(g *globalOptions, env *commandEnv, files []string, opts *gyazo.UploadOptions) (results []uploadResult, ok bool) {
+	if !forwardable(g) {
+		return nil, false
+	}
+	pid, running := instance.Running(instance.PIDPath())
+	if !running {
+		return nil, false
+	}
+	req := forwardUploadRequest{
+		ConfigPath:   g.resolvedConfigPath(),
+		Profile:      env.config.Profile,
+		Desc:         opts.Desc,
+		AccessPolicy: opts.AccessPolicy,
+	}
+	for _, file := range files {
+		// 標準入力は常駐プロセスに渡せない
+		if file == "-" {
+			return nil, false
+		}
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			return nil, false
+		}
+		req.Files = append(req.Files, abs)
+	}
+
+	err := instance.Call(context.Background(), ctlUpload, req, &results)
+	var methodErr *instance.MethodError
+	if errors.As(err, &methodErr) {
+		// 別の設定ファイルを使っている場合など、常駐プロセスが断った場合はこのプロセスでアップロードする
+		slog.Debug("running zgyazo declined the upload, uploading directly", "pid", pid, "error", err)
+		return nil, false
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to forward to the running zgyazo (pid %d), uploading directly: %v\n", pid, err)
+		return nil, false
+	}
+	// 表示はコマンドラインで指定したパスにそろえる
+	for i := range results {
+		if i < len(files) {
+			results[i].File = files[i]
+		}
+		if results[i].Error != "" {
+			fmt.Fprintf(os.Stderr, "Failed to upload %s: %s\n", results[i].File, results[i].Error)
+		}
+	}
+	return results, true
+}
+
+// forwardable は常駐プロセスに転送できるかどうかを返す
+// エンドポイントやトークンを環境変数やフラグで上書きした場合は、常駐プロセスに渡せないため転送しない
+func forwardable(g *globalOptions) bool {
+	return g.endpoint == "" && os.Getenv(envEndpoint) == "" && os.Getenv(envToken) == ""
+}
+
+// checkForwarded は転送された要求が常駐プロセスと同じ設定ファイルとプロファイルを使うか確認する
+// 違う場合はコマンドライン側で実行するように断る
+func (r *configReloader) checkForwarded(configPath, profile string) error {
+	if filepath.Clean(configPath) != filepath.Clean(r.path) {
+		return errors.New("the running zgyazo uses a different config file: " + r.path)
+	}
+	if current := r.currentProfile(); profile != current {
+		return fmt.Errorf("the running zgyazo uses a different profile: %q", current)
+	}
+	return nil
+}
+
+// forwardedUpload は転送された zgyazo upload を実行する
+// 常駐プロセスと違う設定ファイルやプロファイルを使う要求は、コマンドライン側でアップロードするように断る
+func (r *configReloader) forwardedUpload(ctx context.Context, hist *history.Store, req forwardUploadRequest) ([]uploadResult, error) {
+	if err := r.checkForwarded(req.ConfigPath, req.Profile); err != nil {
+		return nil, err
+	}
+	account, err := r.accountForProfile(req.Profile)
+	if err != nil {
+		return nil, err
+	}
+	opts := *account.Options
+	opts.Desc = req.Desc
+	opts.AccessPolicy = req.AccessPolicy
+	account = &uploader.Account{Profile: account.Profile, Client: account.Client, Options: &opts}
+
+	results := make([]uploadResult, 0, len(req.Files))
+	for _, file := range req.Files {
+		res, err := uploader.UploadFile(ctx, account, hist, file)
+		if err != nil {
+			slog.Warn("forwarded upload failed", "file", file, "error", err)
+			results = append(results, uploadResult{File: file, Error: err.Error()})
+			continue
+		}
+		slog.Info("uploaded forwarded file", "file", file, "url", res.PermalinkURL)
+		results = append(results, uploadResult{File: file, ImageID: res.ImageID, PermalinkURL: res.PermalinkURL, URL: res.URL})
+	}
+	return results, nil
+}
+
+// forwardDeleteRequest は zgyazo delete を常駐プロセスに転送するときの要求
+type forwardDeleteRequest struct {
+	// ConfigPath と Profile は、常駐プロセスと同じ設定とプロファイルで削除するかの確認に使う
+	ConfigPath string `json:"config_path"`
+	Profile    string `json:"profile,omitempty"`
+
+	ImageID string `json:"image_id"`
+}
+
+// forwardDeleteResult は転送した zgyazo delete の結果
+type forwardDeleteResult struct {
+	// Error は削除できなかった理由 (削除した場合は空)
+	Error string `json:"error,omitempty"`
+}
+
+// forwardDelete は起動中の常駐プロセスに imageID の画像の削除を依頼する
+// 常駐プロセスが起動していない場合や、常駐プロセスが断った場合は ok に false を返す
+// 常駐プロセスは履歴をメモリに持っていて保存のたびに上書きするため、起動中は常駐プロセスで履歴を更新する
+func forwardDelete(g *globalOptions, env *commandEnv, imageID string) (ok bool, err error) {
+	if !forwardable(g) {
+		return false, nil
+	}
+	pid, running := instance.Running(instance.PIDPath())
+	if !running {
+		return false, nil
+	}
+	req := forwardDeleteRequest{
+		ConfigPath: g.resolvedConfigPath(),
+		Profile:    env.config.Profile,
+		ImageID:    imageID,
+	}
+	var result forwardDeleteResult
+	err = instance.Call(context.Background(), ctlDelete, req, &result)
+	var methodErr *instance.MethodError
+	if errors.As(err, &methodErr) {
+		slog.Debug("running zgyazo declined the deletion, deleting directly", "pid", pid, "error", err)
+		return false, nil
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to forward to the running zgyazo (pid %d), deleting directly: %v\n", pid, err)
+		return false, nil
+	}
+	if result.Error != "" {
+		return true, errors.New(result.Error)
+	}
+	return true, nil
+}
+
+// forwardedDelete は転送された zgyazo delete を実行する
+// 履歴にある画像は、アップロードしたプロファイルのアカウントで削除する
+func (r *configReloader) forwardedDelete(ctx context.Context, hist *history.Store, req forwardDeleteRequest) (forwardDeleteResult, error) {
+	if err := r.checkForwarded(req.ConfigPath, req.Profile); err != nil {
+		return forwardDeleteResult{}, err
+	}
+	profile := req.Profile
+	if entry, ok := hist.Find(req.ImageID); ok {
+		profile = entry.Profile
+	}
+	account, err := r.accountForProfile(profile)
+	if err != nil {
+		return forwardDeleteResult{}, err
+	}
+	if err := deleteImage(ctx, account.Client, hist, req.ImageID); err != nil {
+		slog.Warn("forwarded deletion failed", "image_id", req.ImageID, "error", err)
+		return forwardDeleteResult{Error: err.Error()}, nil
+	}
+	return forwardDeleteResult{}, nil
+}
@@ -8,3 +8,9 @@ require (
 )
 
 require github.com/fsnotify/fsnotify v1.9.0
+
+require (
+	github.com/BurntSushi/toml v1.6.0
+	golang.org/x/term v0.32.0
+	gopkg.in/yaml.v3 v3.0.1
+)
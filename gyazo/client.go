@@ -0,0 +1,177 @@
+// Package gyazo は Gyazo API のクライアントです
+//
+// 対応している API は以下の通り
+//
+//   - 画像のアップロード (Upload)
+//   - 画像一覧の取得 (ListImages)
+//   - 画像の取得 (GetImage)
+//   - 画像の削除 (DeleteImage)
+//   - oEmbed (OEmbed)
+//   - ユーザー情報の取得 (Me)
+//
+// API の仕様は https://gyazo.com/api/docs を参照
+package gyazo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	// DefaultUploadEndpoint は Upload API のエンドポイント
+	DefaultUploadEndpoint = "https://upload.gyazo.com"
+
+	// DefaultAPIEndpoint は Upload 以外の API のエンドポイント
+	DefaultAPIEndpoint = "https://api.gyazo.com"
+)
+
+// Client は Gyazo API を利用するさいのクライアント構造体です
+type Client struct {
+	client *http.Client
+
+	// Upload API endpoint
+	uploadEndpoint string
+
+	// Upload 以外の API endpoint
+	apiEndpoint string
+
+	// トークンを付与する前の HTTP クライアント
+	baseClient *http.Client
+
+	// logger はリクエストのログを出力する (nil の場合は slog.Default())
+	logger *slog.Logger
+}
+
+// Option は NewClient の設定を変更する
+type Option func(*Client)
+
+// WithUploadEndpoint は Upload API のエンドポイントを変更する
+// テスト用のサーバーや互換サーバーを使う場合に指定する
+func WithUploadEndpoint(endpoint string) Option {
+	return func(c *Client) {
+		c.uploadEndpoint = endpoint
+	}
+}
+
+// WithAPIEndpoint は Upload 以外の API のエンドポイントを変更する
+func WithAPIEndpoint(endpoint string) Option {
+	return func(c *Client) {
+		c.apiEndpoint = endpoint
+	}
+}
+
+// WithHTTPClient はリクエストに使う HTTP クライアントを変更する
+// アクセストークンはこのクライアントのリクエストに付与される
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.baseClient = httpClient
+	}
+}
+
+// WithLogger はリクエストのログの出力先を変更する
+// 指定しない場合は slog.Default() に出力する
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *Client) {
+		c.logger = logger
+	}
+}
+
+// NewClient は Gyazo API を扱うクライアントを生成します。
+func NewClient(token string, opts ...Option) (*Client, error) {
+
+	if token == "" {
+		return nil, errors.New("token must not be empty")
+	}
+
+	c := &Client{
+		uploadEndpoint: DefaultUploadEndpoint,
+		apiEndpoint:    DefaultAPIEndpoint,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	ctx := context.Background()
+	if c.baseClient != nil {
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, c.baseClient)
+	}
+	c.client = oauth2.NewClient(
+		ctx,
+		oauth2.StaticTokenSource(
+			&oauth2.Token{AccessToken: token},
+		),
+	)
+	return c, nil
+}
+
+// APIError は Gyazo API が 200 以外のステータスを返したときのエラー
+type APIError struct {
+	// StatusCode は HTTP ステータスコード
+	StatusCode int
+
+	// Message は API が返したエラーメッセージ (返されなかった場合は空)
+	Message string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("gyazo: API returned status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("gyazo: API returned status %d: %s", e.StatusCode, e.Message)
+}
+
+// IsUnauthorized は err がアクセストークンが無効・失効していることを表す APIError かどうかを返す
+func IsUnauthorized(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized
+}
+
+// do は req を送信し、成功した場合はレスポンスボディを v にデコードする
+// v が nil の場合はボディを読み捨てる
+// エラーは呼び出し側に返すため、ログは DEBUG で出力する
+func (c *Client) do(req *http.Request, v any) (*http.Response, error) {
+	logger := c.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger = logger.With("method", req.Method, "path", req.URL.Path)
+
+	start := time.Now()
+	res, err := c.client.Do(req)
+	if err != nil {
+		logger.Debug("HTTP request failed", "error", err)
+		return nil, err
+	}
+	defer res.Body.Close()
+	logger.Debug("HTTP response received", "status", res.StatusCode, "duration", time.Since(start))
+
+	if res.StatusCode != http.StatusOK {
+		apiErr := &APIError{StatusCode: res.StatusCode}
+		var body struct {
+			Message string `json:"message"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&body); err == nil {
+			apiErr.Message = body.Message
+		}
+		logger.Debug("API returned an error", "status", res.StatusCode, "error", apiErr)
+		return res, apiErr
+	}
+
+	if v == nil {
+		_, err = io.Copy(io.Discard, res.Body)
+		return res, err
+	}
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		logger.Debug("failed to decode response", "error", err)
+		return res, err
+	}
+	return res, nil
+}
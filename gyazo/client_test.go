@@ -0,0 +1,226 @@
+package gyazo_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zztkm/zgyazo/gyazo"
+	"github.com/zztkm/zgyazo/gyazo/gyazotest"
+)
+
+const testToken = "test-token"
+
+func newTestClient(t *testing.T) (*gyazotest.Server, *gyazo.Client) {
+	t.Helper()
+	srv := gyazotest.NewServer(testToken)
+	t.Cleanup(srv.Close)
+	client, err := srv.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv, client
+}
+
+func upload(t *testing.T, client *gyazo.Client, data string) *gyazo.UploadResponse {
+	t.Helper()
+	res, err := client.Upload(context.Background(), "a.png", strings.NewReader(data), nil)
+	if err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	return res
+}
+
+func TestUploadSendsImageAndMetadata(t *testing.T) {
+	srv, client := newTestClient(t)
+	opts := &gyazo.UploadOptions{
+		App:        "zgyazo",
+		Title:      "screenshot",
+		RefererURL: "https://example.com/",
+		Desc:       "#memo",
+	}
+	res, err := client.Upload(context.Background(), "a.png", strings.NewReader("png data"), opts)
+	if err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	if res.ImageID == "" || res.PermalinkURL != srv.URL+"/"+res.ImageID {
+		t.Errorf("unexpected response: %+v", res)
+	}
+
+	data, ok := srv.ImageData(res.ImageID)
+	if !ok || string(data) != "png data" {
+		t.Errorf("uploaded data = %q (found %v), want %q", data, ok, "png data")
+	}
+	image, err := client.GetImage(context.Background(), res.ImageID)
+	if err != nil {
+		t.Fatalf("GetImage: %v", err)
+	}
+	want := gyazo.Metadata{App: "zgyazo", Title: "screenshot", URL: "https://example.com/", Desc: "#memo"}
+	if image.Metadata != want {
+		t.Errorf("metadata = %+v, want %+v", image.Metadata, want)
+	}
+}
+
+func TestUploadMultipartFields(t *testing.T) {
+	// fake サーバーが保存しない項目も含めて、送信したフィールドを確認する
+	var form map[string][]string
+	var file []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		form = r.MultipartForm.Value
+		f, _, err := r.FormFile("imagedata")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer f.Close()
+		var buf bytes.Buffer
+		buf.ReadFrom(f)
+		file = buf.Bytes()
+		w.Write([]byte(`{"image_id":"abc"}`))
+	}))
+	defer srv.Close()
+	client, err := gyazo.NewClient(testToken, gyazo.WithUploadEndpoint(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	createdAt := time.Unix(1700000000, 0)
+	opts := &gyazo.UploadOptions{
+		AccessPolicy:     gyazo.AccessPolicyOnlyMe,
+		MetadataIsPublic: gyazo.Bool(false),
+		Desc:             "desc",
+		CreatedAt:        createdAt,
+		CollectionID:     "col",
+	}
+	if _, err := client.Upload(context.Background(), "a.png", strings.NewReader("png"), opts); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+
+	want := map[string]string{
+		"access_policy":      "only_me",
+		"metadata_is_public": "false",
+		"desc":               "desc",
+		"created_at":         "1700000000",
+		"collection_id":      "col",
+	}
+	for name, value := range want {
+		if got := form[name]; len(got) != 1 || got[0] != value {
+			t.Errorf("field %s = %q, want %q", name, got, value)
+		}
+	}
+	for _, name := range []string{"app", "title", "referer_url"} {
+		if _, ok := form[name]; ok {
+			t.Errorf("empty field %s should not be sent", name)
+		}
+	}
+	if string(file) != "png" {
+		t.Errorf("imagedata = %q, want %q", file, "png")
+	}
+}
+
+func TestListImagesPagination(t *testing.T) {
+	_, client := newTestClient(t)
+	for i := range 5 {
+		upload(t, client, strings.Repeat("x", i+1))
+	}
+
+	var ids []string
+	page := 1
+	for page != 0 {
+		list, err := client.ListImages(context.Background(), &gyazo.ListOptions{Page: page, PerPage: 2})
+		if err != nil {
+			t.Fatalf("ListImages(page %d): %v", page, err)
+		}
+		if list.TotalCount != 5 || list.CurrentPage != page || list.PerPage != 2 {
+			t.Errorf("page %d: total=%d current=%d per_page=%d", page, list.TotalCount, list.CurrentPage, list.PerPage)
+		}
+		for _, image := range list.Images {
+			ids = append(ids, image.ImageID)
+		}
+		page = list.NextPage()
+	}
+
+	if len(ids) != 5 {
+		t.Fatalf("listed %d images, want 5: %v", len(ids), ids)
+	}
+	for i := 1; i < len(ids); i++ {
+		if ids[i-1] <= ids[i] {
+			t.Errorf("images are not in newest-first order: %v", ids)
+		}
+	}
+}
+
+func TestUnauthorized(t *testing.T) {
+	srv, _ := newTestClient(t)
+	client, err := gyazo.NewClient("wrong-token", gyazo.WithUploadEndpoint(srv.URL), gyazo.WithAPIEndpoint(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Me(context.Background())
+	if !gyazo.IsUnauthorized(err) {
+		t.Fatalf("Me with a wrong token: err = %v, want unauthorized", err)
+	}
+	var apiErr *gyazo.APIError
+	if !errors.As(err, &apiErr) || apiErr.Message == "" {
+		t.Errorf("error message was not decoded: %#v", err)
+	}
+
+	_, err = client.Upload(context.Background(), "a.png", strings.NewReader("png"), nil)
+	if !gyazo.IsUnauthorized(err) {
+		t.Errorf("Upload with a wrong token: err = %v, want unauthorized", err)
+	}
+	if gyazo.IsUnauthorized(errors.New("other")) {
+		t.Error("IsUnauthorized should be false for other errors")
+	}
+}
+
+func TestDeleteImage(t *testing.T) {
+	srv, client := newTestClient(t)
+	res := upload(t, client, "png")
+
+	deleted, err := client.DeleteImage(context.Background(), res.ImageID)
+	if err != nil {
+		t.Fatalf("DeleteImage: %v", err)
+	}
+	if deleted.ImageID != res.ImageID {
+		t.Errorf("deleted %q, want %q", deleted.ImageID, res.ImageID)
+	}
+	if len(srv.Images()) != 0 {
+		t.Errorf("image was not deleted: %v", srv.Images())
+	}
+
+	_, err = client.DeleteImage(context.Background(), res.ImageID)
+	var apiErr *gyazo.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || apiErr.Message != "image not found" {
+		t.Errorf("deleting twice: err = %#v, want 404 with the API message", err)
+	}
+}
+
+func TestOEmbed(t *testing.T) {
+	_, client := newTestClient(t)
+	res := upload(t, client, "png")
+
+	embed, err := client.OEmbed(context.Background(), res.PermalinkURL)
+	if err != nil {
+		t.Fatalf("OEmbed: %v", err)
+	}
+	if embed.Type != "photo" || embed.URL != res.URL {
+		t.Errorf("unexpected oEmbed: %+v", embed)
+	}
+
+	_, err = client.OEmbed(context.Background(), res.PermalinkURL+"0")
+	var apiErr *gyazo.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || apiErr.Message != "image not found" {
+		t.Errorf("oEmbed of an unknown image: err = %#v, want 404 with the API message", err)
+	}
+}
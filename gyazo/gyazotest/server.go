@@ -0,0 +1,376 @@
+// Package gyazotest は Gyazo API の fake サーバーを提供する
+// gyazo.Client や Uploader を実際の Gyazo に接続せずに動かすためのもの
+package gyazotest
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zztkm/zgyazo/gyazo"
+)
+
+// Server は Gyazo API の fake サーバー
+// Upload API もそれ以外の API も同じ URL で受け付けるので、
+// gyazo.WithUploadEndpoint と gyazo.WithAPIEndpoint の両方に URL を指定する
+//
+// OAuth2 の認可コードフローのエンドポイント (/oauth/authorize, /oauth/token, /oauth/revoke) もある
+// /oauth/authorize はログインと認可の画面を出さず、すぐに redirect_uri にリダイレクトする
+type Server struct {
+	// URL は fake サーバーのベース URL
+	URL string
+
+	server *httptest.Server
+	token  string
+
+	mu     sync.Mutex
+	nextID int
+	images map[string]*storedImage
+	user   gyazo.User
+
+	// codes は発行した認可コードと、その認可リクエスト
+	codes    map[string]authorization
+	nextCode int
+	revoked  bool
+}
+
+// authorization は認可コードを発行した認可リクエスト
+type authorization struct {
+	redirectURI   string
+	codeChallenge string
+}
+
+// storedImage はアップロードされた画像とその内容
+type storedImage struct {
+	image gyazo.Image
+	data  []byte
+}
+
+// NewServer は token を持つリクエストだけを受け付ける fake サーバーを起動する
+func NewServer(token string) *Server {
+	s := &Server{
+		token:  token,
+		images: make(map[string]*storedImage),
+		codes:  make(map[string]authorization),
+		user: gyazo.User{
+			Email: "gyazotest@example.com",
+			Name:  "gyazotest",
+			UID:   "gyazotest",
+			Plan:  "free",
+		},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/upload", s.handleUpload)
+	mux.HandleFunc("GET /api/images", s.handleList)
+	mux.HandleFunc("GET /api/images/{id}", s.handleGet)
+	mux.HandleFunc("DELETE /api/images/{id}", s.handleDelete)
+	mux.HandleFunc("GET /api/oembed", s.handleOEmbed)
+	mux.HandleFunc("GET /api/users/me", s.handleMe)
+	mux.HandleFunc("GET /oauth/authorize", s.handleAuthorize)
+	mux.HandleFunc("POST /oauth/token", s.handleToken)
+	mux.HandleFunc("POST /oauth/revoke", s.handleRevoke)
+	s.server = httptest.NewServer(s.authenticate(mux))
+	s.URL = s.server.URL
+	return s
+}
+
+// Close はサーバーを停止する
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Client は fake サーバーに接続する gyazo.Client を生成する
+func (s *Server) Client(opts ...gyazo.Option) (*gyazo.Client, error) {
+	opts = append([]gyazo.Option{
+		gyazo.WithUploadEndpoint(s.URL),
+		gyazo.WithAPIEndpoint(s.URL),
+	}, opts...)
+	return gyazo.NewClient(s.token, opts...)
+}
+
+// SetUser は /api/users/me が返すユーザー情報を変更する
+func (s *Server) SetUser(user gyazo.User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.user = user
+}
+
+// Revoked は /oauth/revoke でトークンが失効したかどうかを返す
+// 失効したあとはすべての API が 401 を返す
+func (s *Server) Revoked() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.revoked
+}
+
+// Images はアップロードされている画像を新しい順に返す
+func (s *Server) Images() []gyazo.Image {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sortedImages()
+}
+
+// ImageData は imageID の画像としてアップロードされた内容を返す
+func (s *Server) ImageData(imageID string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.images[imageID]
+	if !ok {
+		return nil, false
+	}
+	return stored.data, true
+}
+
+func (s *Server) sortedImages() []gyazo.Image {
+	images := make([]gyazo.Image, 0, len(s.images))
+	for _, stored := range s.images {
+		images = append(images, stored.image)
+	}
+	sort.Slice(images, func(i, j int) bool {
+		return images[i].ImageID > images[j].ImageID
+	})
+	return images
+}
+
+// authenticate は oEmbed と OAuth 以外の API で Bearer トークンを検証する
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/oembed" || strings.HasPrefix(r.URL.Path, "/oauth/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		s.mu.Lock()
+		revoked := s.revoked
+		s.mu.Unlock()
+		if revoked || r.Header.Get("Authorization") != "Bearer "+s.token {
+			writeError(w, http.StatusUnauthorized, "You are not authorized.")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
+	file, _, err := r.FormFile("imagedata")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "imagedata is required")
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	s.nextID++
+	// 新しい順に並べられるように連番を固定長の ID にする
+	id := fmt.Sprintf("%032x", s.nextID)
+	image := gyazo.Image{
+		ImageID:      id,
+		PermalinkURL: s.URL + "/" + id,
+		ThumbURL:     s.URL + "/thumb/" + id + ".png",
+		URL:          s.URL + "/" + id + ".png",
+		Type:         "png",
+		CreatedAt:    time.Now().UTC().Truncate(time.Second),
+		Metadata: gyazo.Metadata{
+			App:   r.FormValue("app"),
+			Title: r.FormValue("title"),
+			URL:   r.FormValue("referer_url"),
+			Desc:  r.FormValue("desc"),
+		},
+	}
+	s.images[id] = &storedImage{image: image, data: data}
+	s.mu.Unlock()
+
+	writeJSON(w, gyazo.UploadResponse{
+		ImageID:      image.ImageID,
+		PermalinkURL: image.PermalinkURL,
+		ThumbURL:     image.ThumbURL,
+		URL:          image.URL,
+		Type:         image.Type,
+	})
+}
+
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	page := queryInt(r, "page", 1)
+	perPage := queryInt(r, "per_page", 20)
+	if page < 1 || perPage < 1 || perPage > 100 {
+		writeError(w, http.StatusUnprocessableEntity, "invalid page or per_page")
+		return
+	}
+
+	s.mu.Lock()
+	images := s.sortedImages()
+	s.mu.Unlock()
+
+	start := min((page-1)*perPage, len(images))
+	end := min(start+perPage, len(images))
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(images)))
+	w.Header().Set("X-Current-Page", strconv.Itoa(page))
+	w.Header().Set("X-Per-Page", strconv.Itoa(perPage))
+	w.Header().Set("X-User-Type", "lite")
+	writeJSON(w, images[start:end])
+}
+
+func (s *Server) handleGet(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	stored, ok := s.images[r.PathValue("id")]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "image not found")
+		return
+	}
+	writeJSON(w, stored.image)
+}
+
+func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	s.mu.Lock()
+	stored, ok := s.images[id]
+	delete(s.images, id)
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "image not found")
+		return
+	}
+	writeJSON(w, gyazo.DeleteResponse{ImageID: id, Type: stored.image.Type})
+}
+
+func (s *Server) handleOEmbed(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Query().Get("url"), s.URL+"/")
+	s.mu.Lock()
+	stored, ok := s.images[id]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "image not found")
+		return
+	}
+	writeJSON(w, gyazo.OEmbed{
+		Version:      "1.0",
+		Type:         "photo",
+		ProviderName: "Gyazo",
+		ProviderURL:  "https://gyazo.com",
+		URL:          stored.image.URL,
+		Width:        1,
+		Height:       1,
+	})
+}
+
+func (s *Server) handleMe(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	user := s.user
+	s.mu.Unlock()
+	writeJSON(w, map[string]gyazo.User{"user": user})
+}
+
+// handleAuthorize は認可リクエストをすぐに承認し、認可コードを付けて redirect_uri にリダイレクトする
+func (s *Server) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	redirectURI, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || redirectURI.Scheme == "" || q.Get("client_id") == "" || q.Get("response_type") != "code" {
+		writeError(w, http.StatusBadRequest, "invalid authorization request")
+		return
+	}
+	if q.Get("code_challenge") != "" && q.Get("code_challenge_method") != "S256" {
+		writeError(w, http.StatusBadRequest, "code_challenge_method must be S256")
+		return
+	}
+
+	s.mu.Lock()
+	s.nextCode++
+	code := fmt.Sprintf("code%d", s.nextCode)
+	s.codes[code] = authorization{redirectURI: redirectURI.String(), codeChallenge: q.Get("code_challenge")}
+	s.mu.Unlock()
+
+	params := redirectURI.Query()
+	params.Set("code", code)
+	params.Set("state", q.Get("state"))
+	redirectURI.RawQuery = params.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+// handleToken は認可コードをアクセストークンと交換する
+// 認可コードは 1 度だけ使え、PKCE を使った認可リクエストの場合は code_verifier を検証する
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if r.PostFormValue("grant_type") != "authorization_code" {
+		writeOAuthError(w, "unsupported_grant_type")
+		return
+	}
+	s.mu.Lock()
+	authz, ok := s.codes[r.PostFormValue("code")]
+	delete(s.codes, r.PostFormValue("code"))
+	s.mu.Unlock()
+	if !ok || authz.redirectURI != r.PostFormValue("redirect_uri") {
+		writeOAuthError(w, "invalid_grant")
+		return
+	}
+	if authz.codeChallenge != "" {
+		sum := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+		if base64.RawURLEncoding.EncodeToString(sum[:]) != authz.codeChallenge {
+			writeOAuthError(w, "invalid_grant")
+			return
+		}
+	}
+
+	s.mu.Lock()
+	s.revoked = false
+	s.mu.Unlock()
+	writeJSON(w, map[string]string{
+		"access_token": s.token,
+		"token_type":   "bearer",
+		"scope":        "public",
+	})
+}
+
+// handleRevoke は RFC 7009 のトークン失効エンドポイント
+func (s *Server) handleRevoke(w http.ResponseWriter, r *http.Request) {
+	if r.PostFormValue("token") == s.token {
+		s.mu.Lock()
+		s.revoked = true
+		s.mu.Unlock()
+	}
+	// RFC 7009 では不明なトークンでも 200 を返す
+	w.WriteHeader(http.StatusOK)
+}
+
+func queryInt(r *http.Request, name string, fallback int) int {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeOAuthError は RFC 6749 の形式のエラーを返す
+func writeOAuthError(w http.ResponseWriter, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]string{"error": code})
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"message": message})
+}
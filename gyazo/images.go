@@ -0,0 +1,152 @@
+package gyazo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Image は Gyazo に保存されている画像を表現する構造体です
+type Image struct {
+	ImageID      string    `json:"image_id"`
+	PermalinkURL string    `json:"permalink_url"`
+	ThumbURL     string    `json:"thumb_url"`
+	URL          string    `json:"url"`
+	Type         string    `json:"type"`
+	CreatedAt    time.Time `json:"created_at"`
+	Metadata     Metadata  `json:"metadata"`
+	OCR          *OCR      `json:"ocr,omitempty"`
+}
+
+// Metadata は画像のキャプチャ元の情報
+type Metadata struct {
+	App   string `json:"app"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+	Desc  string `json:"desc"`
+}
+
+// OCR は画像から読み取られたテキスト
+type OCR struct {
+	Locale      string `json:"locale"`
+	Description string `json:"description"`
+}
+
+// ListOptions は ListImages のページ指定
+// ゼロ値のフィールドは API のデフォルト値になる
+type ListOptions struct {
+	// Page は 1 から始まるページ番号
+	Page int
+
+	// PerPage は 1 ページあたりの件数 (最大 100)
+	PerPage int
+}
+
+// ImageList は ListImages の結果
+// ページ情報はレスポンスヘッダから取得する
+type ImageList struct {
+	Images      []Image
+	TotalCount  int
+	CurrentPage int
+	PerPage     int
+	UserType    string
+}
+
+// NextPage は次のページ番号を返す。次のページがない場合は 0 を返す
+func (l *ImageList) NextPage() int {
+	if l.PerPage <= 0 || l.CurrentPage*l.PerPage >= l.TotalCount {
+		return 0
+	}
+	return l.CurrentPage + 1
+}
+
+// ListImages はユーザーがアップロードした画像の一覧を取得する
+func (c *Client) ListImages(ctx context.Context, opts *ListOptions) (*ImageList, error) {
+	query := url.Values{}
+	if opts != nil {
+		if opts.Page > 0 {
+			query.Set("page", strconv.Itoa(opts.Page))
+		}
+		if opts.PerPage > 0 {
+			query.Set("per_page", strconv.Itoa(opts.PerPage))
+		}
+	}
+	endpoint := c.apiEndpoint + "/api/images"
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &ImageList{}
+	res, err := c.do(req, &list.Images)
+	if err != nil {
+		return nil, err
+	}
+	list.TotalCount, _ = strconv.Atoi(res.Header.Get("X-Total-Count"))
+	list.CurrentPage, _ = strconv.Atoi(res.Header.Get("X-Current-Page"))
+	list.PerPage, _ = strconv.Atoi(res.Header.Get("X-Per-Page"))
+	list.UserType = res.Header.Get("X-User-Type")
+	return list, nil
+}
+
+// GetImage は imageID の画像の情報を取得する
+func (c *Client) GetImage(ctx context.Context, imageID string) (*Image, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.apiEndpoint+"/api/images/"+url.PathEscape(imageID), nil)
+	if err != nil {
+		return nil, err
+	}
+	var image Image
+	if _, err := c.do(req, &image); err != nil {
+		return nil, err
+	}
+	return &image, nil
+}
+
+// DeleteResponse は DeleteImage のレスポンス
+type DeleteResponse struct {
+	ImageID string `json:"image_id"`
+	Type    string `json:"type"`
+}
+
+// DeleteImage は imageID の画像を削除する
+func (c *Client) DeleteImage(ctx context.Context, imageID string) (*DeleteResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, c.apiEndpoint+"/api/images/"+url.PathEscape(imageID), nil)
+	if err != nil {
+		return nil, err
+	}
+	var deleteResp DeleteResponse
+	if _, err := c.do(req, &deleteResp); err != nil {
+		return nil, err
+	}
+	return &deleteResp, nil
+}
+
+// ParseImageID は画像 ID、または画像ページや画像ファイルの URL から画像 ID を取り出す
+//
+//	8980c52421e452ac3355ca3e5cfe7a0c
+//	https://gyazo.com/8980c52421e452ac3355ca3e5cfe7a0c
+//	https://i.gyazo.com/8980c52421e452ac3355ca3e5cfe7a0c.png
+func ParseImageID(s string) (string, error) {
+	id := strings.TrimSpace(s)
+	if u, err := url.Parse(id); err == nil && u.Scheme != "" {
+		id = path.Base(u.Path)
+	}
+	id = strings.TrimSuffix(id, path.Ext(id))
+	if len(id) != 32 {
+		return "", fmt.Errorf("gyazo: %q is not an image ID or image URL", s)
+	}
+	for _, c := range id {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return "", fmt.Errorf("gyazo: %q is not an image ID or image URL", s)
+		}
+	}
+	return id, nil
+}
@@ -0,0 +1,16 @@
+package gyazo
+
+import "golang.org/x/oauth2"
+
+// Gyazo の OAuth2 のエンドポイント
+// アクセストークンは https://gyazo.com/oauth/applications に登録したアプリケーションで認可コードフローを行って取得する
+const (
+	DefaultAuthorizeURL = "https://gyazo.com/oauth/authorize"
+	DefaultTokenURL     = "https://gyazo.com/oauth/token"
+)
+
+// OAuthEndpoint は Gyazo の OAuth2 のエンドポイント
+var OAuthEndpoint = oauth2.Endpoint{
+	AuthURL:  DefaultAuthorizeURL,
+	TokenURL: DefaultTokenURL,
+}
@@ -0,0 +1,33 @@
+package gyazo
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// OEmbed は oEmbed API のレスポンス
+type OEmbed struct {
+	Version      string `json:"version"`
+	Type         string `json:"type"`
+	ProviderName string `json:"provider_name"`
+	ProviderURL  string `json:"provider_url"`
+	URL          string `json:"url"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+}
+
+// OEmbed は Gyazo の画像ページの URL (permalink_url) から埋め込み用の情報を取得する
+// oEmbed API は認証不要だが、ほかの API と同じクライアントで呼び出す
+func (c *Client) OEmbed(ctx context.Context, permalinkURL string) (*OEmbed, error) {
+	query := url.Values{"url": {permalinkURL}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.apiEndpoint+"/api/oembed?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	var oembed OEmbed
+	if _, err := c.do(req, &oembed); err != nil {
+		return nil, err
+	}
+	return &oembed, nil
+}
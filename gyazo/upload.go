@@ -0,0 +1,132 @@
+package gyazo
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// UploadResponse は Gyazo にファイルをアップロードしたときのレスポンスを表現する構造体です
+// 例
+//
+//	{
+//	  "image_id" : "8980c52421e452ac3355ca3e5cfe7a0c",
+//	  "permalink_url": "http://gyazo.com/8980c52421e452ac3355ca3e5cfe7a0c",
+//	  "thumb_url" : "https://i.gyazo.com/thumb/180/afaiefnaf.png",
+//	  "url" : "https://i.gyazo.com/8980c52421e452ac3355ca3e5cfe7a0c.png",
+//	  "type": "png"
+//	}
+type UploadResponse struct {
+	ImageID      string `json:"image_id"`
+	PermalinkURL string `json:"permalink_url"`
+	ThumbURL     string `json:"thumb_url"`
+	URL          string `json:"url"`
+	Type         string `json:"type"`
+}
+
+// アクセスポリシー (UploadOptions.AccessPolicy に指定する値)
+const (
+	AccessPolicyAnyone = "anyone"
+	AccessPolicyOnlyMe = "only_me"
+)
+
+// UploadOptions は Upload API の任意パラメータ
+// ゼロ値のフィールドは送信しない
+type UploadOptions struct {
+	// AccessPolicy は画像の公開範囲 (AccessPolicyAnyone または AccessPolicyOnlyMe)
+	AccessPolicy string
+
+	// MetadataIsPublic は URL やタイトルなどのメタデータを公開するかどうか
+	MetadataIsPublic *bool
+
+	// RefererURL はキャプチャ元の URL
+	RefererURL string
+
+	// App はキャプチャ元のアプリケーション名
+	App string
+
+	// Title はキャプチャ元のタイトル
+	Title string
+
+	// Desc は画像の説明
+	Desc string
+
+	// CreatedAt は画像の作成日時
+	CreatedAt time.Time
+
+	// CollectionID は画像を追加するコレクションの ID
+	CollectionID string
+}
+
+// Bool は UploadOptions.MetadataIsPublic などに指定するためのポインタを返す
+func Bool(v bool) *bool {
+	return &v
+}
+
+func (o *UploadOptions) writeFields(w *multipart.Writer) error {
+	if o == nil {
+		return nil
+	}
+	fields := []struct{ name, value string }{
+		{"access_policy", o.AccessPolicy},
+		{"referer_url", o.RefererURL},
+		{"app", o.App},
+		{"title", o.Title},
+		{"desc", o.Desc},
+		{"collection_id", o.CollectionID},
+	}
+	if o.MetadataIsPublic != nil {
+		fields = append(fields, struct{ name, value string }{"metadata_is_public", strconv.FormatBool(*o.MetadataIsPublic)})
+	}
+	if !o.CreatedAt.IsZero() {
+		fields = append(fields, struct{ name, value string }{"created_at", strconv.FormatInt(o.CreatedAt.Unix(), 10)})
+	}
+	for _, f := range fields {
+		if f.value == "" {
+			continue
+		}
+		if err := w.WriteField(f.name, f.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Upload は r から読み込んだ画像を filename として Gyazo にアップロードする
+// opts が nil の場合は任意パラメータを送信しない
+func (c *Client) Upload(ctx context.Context, filename string, r io.Reader, opts *UploadOptions) (*UploadResponse, error) {
+	var body bytes.Buffer
+	multipartWriter := multipart.NewWriter(&body)
+
+	if err := opts.writeFields(multipartWriter); err != nil {
+		return nil, err
+	}
+
+	partWriter, err := multipartWriter.CreateFormFile("imagedata", filename)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(partWriter, r); err != nil {
+		return nil, err
+	}
+	err = multipartWriter.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.uploadEndpoint+"/api/upload", &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", multipartWriter.FormDataContentType())
+
+	var uploadResp UploadResponse
+	if _, err := c.do(req, &uploadResp); err != nil {
+		return nil, err
+	}
+	return &uploadResp, nil
+}
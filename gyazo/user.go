@@ -0,0 +1,33 @@
+package gyazo
+
+import (
+	"context"
+	"net/http"
+)
+
+// User はアクセストークンの持ち主のユーザー情報
+type User struct {
+	Email        string `json:"email"`
+	Name         string `json:"name"`
+	ProfileImage string `json:"profile_image"`
+	UID          string `json:"uid"`
+
+	// Plan は契約しているプラン ("free", "pro" など)
+	// 返さないサーバーもあるため、空の場合がある
+	Plan string `json:"plan,omitempty"`
+}
+
+// Me はアクセストークンの持ち主のユーザー情報を取得する
+func (c *Client) Me(ctx context.Context) (*User, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.apiEndpoint+"/api/users/me", nil)
+	if err != nil {
+		return nil, err
+	}
+	var body struct {
+		User User `json:"user"`
+	}
+	if _, err := c.do(req, &body); err != nil {
+		return nil, err
+	}
+	return &body.User, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/zztkm/zgyazo/internal/history"
+)
+
+// runHistoryCommand は zgyazo history を実行し、終了コードを返す
+func runHistoryCommand(g *globalOptions, args []string) int {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	limit := fs.Int("n", 20, "表示する件数 (0 の場合はすべて)")
+	asJSON := fs.Bool("json", false, "JSON で出力する")
+	showDeleted := fs.Bool("deleted", false, "削除済みの画像も表示する")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: zgyazo history [flags]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := g.setupCommandLogger(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	hist, err := history.Open(history.DefaultPath())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open history: %v\n", err)
+		return 1
+	}
+
+	// 新しい順に limit 件まで表示する
+	var entries []history.Entry
+	all := hist.Entries()
+	for i := len(all) - 1; i >= 0; i-- {
+		if *limit > 0 && len(entries) >= *limit {
+			break
+		}
+		if all[i].Deleted() && !*showDeleted {
+			continue
+		}
+		entries = append(entries, all[i])
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(entries); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+	for _, e := range entries {
+		status := ""
+		if e.Deleted() {
+			status = " (deleted)"
+		}
+		fmt.Printf("%s  %s  %s%s\n", e.UploadedAt.Local().Format(time.DateTime), e.PermalinkURL, e.FilePath, status)
+	}
+	return 0
+}
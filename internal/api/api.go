@@ -0,0 +1,98 @@
+// Package api は常駐プロセスの状態を確認・操作するローカルの HTTP API
+//
+// API は 127.0.0.1 だけで待ち受け、状態ディレクトリの api.token に保存したトークンを
+// Authorization: Bearer ヘッダーで送ったリクエストだけを受け付ける
+//
+//	GET  /v1/status              状態 (キュー、アップロード中、リトライ、失敗、最近のアップロード、監視)
+//	POST /v1/pause               アップロードを一時停止する ({"duration": "30m"} で自動で再開する)
+//	POST /v1/resume              一時停止を解除する
+//	POST /v1/enqueue             {"path": "..."} の画像をキューに追加する
+//	POST /v1/dead-letters/retry  リトライしても失敗した画像をキューに追加し直す
+//	POST /v1/shutdown            常駐プロセスを終了する
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/zztkm/zgyazo/internal/deadletter"
+	"github.com/zztkm/zgyazo/internal/history"
+	"github.com/zztkm/zgyazo/internal/logging"
+	"github.com/zztkm/zgyazo/internal/platform"
+	"github.com/zztkm/zgyazo/internal/uploader"
+)
+
+var logger = logging.Logger(logging.ComponentAPI)
+
+// Status は GET /v1/status のレスポンス
+type Status struct {
+	Version   string    `json:"version"`
+	PID       int       `json:"pid"`
+	StartedAt time.Time `json:"started_at"`
+
+	// Profile は使用中のプロファイル (使っていない場合は空)
+	Profile string `json:"profile,omitempty"`
+
+	uploader.Status
+
+	// DeadLetters はリトライしてもアップロードできなかった画像
+	DeadLetters []deadletter.Entry `json:"dead_letters"`
+
+	// RecentUploads は最近アップロードした画像 (新しい順)
+	RecentUploads []history.Entry `json:"recent_uploads"`
+}
+
+// Backend は API の操作を実行する常駐プロセス
+type Backend interface {
+	Status() Status
+	// Pause は after が 0 の場合、設定ファイルの pause_auto_resume が経過したら自動で再開する
+	Pause(after time.Duration)
+	Resume()
+	Enqueue(filePath string) error
+	RetryDeadLetters() (int, error)
+	Shutdown()
+}
+
+// TokenPath はトークンを保存するファイルのパスを返す
+func TokenPath() string {
+	return filepath.Join(platform.StateDir(), "api.token")
+}
+
+// LoadOrCreateToken は path のトークンを読み込む
+// ファイルがない場合はランダムなトークンを生成し、所有者だけが読めるパーミッションで保存する
+func LoadOrCreateToken(path string) (string, error) {
+	token, err := ReadToken(path)
+	if !errors.Is(err, os.ErrNotExist) {
+		return token, err
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token = hex.EncodeToString(b)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(token+"\n"), 0600); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ReadToken は path のトークンを読み込む
+func ReadToken(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", errors.New(path + " is empty")
+	}
+	return token, nil
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Client は常駐プロセスの API を呼び出す
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// NewClient は 127.0.0.1:port の API を呼び出す Client を生成する
+func NewClient(port int, token string) *Client {
+	return &Client{
+		baseURL:    "http://" + net.JoinHostPort("127.0.0.1", strconv.Itoa(port)),
+		token:      token,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Status は常駐プロセスの状態を返す
+func (c *Client) Status(ctx context.Context) (*Status, error) {
+	var s Status
+	if err := c.do(ctx, http.MethodGet, "/v1/status", nil, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Pause はアップロードを一時停止し、停止後の状態を返す
+// after が 0 より大きい場合は after が経過したら自動で再開する
+func (c *Client) Pause(ctx context.Context, after time.Duration) (*Status, error) {
+	var req PauseRequest
+	if after > 0 {
+		req.Duration = after.String()
+	}
+	var s Status
+	if err := c.do(ctx, http.MethodPost, "/v1/pause", req, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Resume は一時停止を解除し、解除後の状態を返す
+func (c *Client) Resume(ctx context.Context) (*Status, error) {
+	var s Status
+	if err := c.do(ctx, http.MethodPost, "/v1/resume", nil, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Enqueue は絶対パス path の画像をキューに追加する
+func (c *Client) Enqueue(ctx context.Context, path string) error {
+	return c.do(ctx, http.MethodPost, "/v1/enqueue", EnqueueRequest{Path: path}, nil)
+}
+
+// RetryDeadLetters はリトライしても失敗した画像をキューに追加し直し、追加した件数を返す
+func (c *Client) RetryDeadLetters(ctx context.Context) (int, error) {
+	var res RetryResponse
+	if err := c.do(ctx, http.MethodPost, "/v1/dead-letters/retry", nil, &res); err != nil {
+		return 0, err
+	}
+	return res.Queued, nil
+}
+
+// Shutdown は常駐プロセスを終了する
+// 終了を受け付けた時点で返り、終了するまでは待たない
+func (c *Client) Shutdown(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/v1/shutdown", nil, nil)
+}
+
+// StatusError は API がエラーを返したことを表す
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("API returned %d: %s", e.StatusCode, e.Message)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, r)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var e errorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&e); err != nil || e.Error == "" {
+			e.Error = http.StatusText(resp.StatusCode)
+		}
+		return &StatusError{StatusCode: resp.StatusCode, Message: e.Error}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
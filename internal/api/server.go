@@ -0,0 +1,192 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zztkm/zgyazo/internal/uploader"
+)
+
+// maxRequestBody はリクエストボディの最大サイズ
+const maxRequestBody = 64 * 1024
+
+// Server はループバックアドレスで待ち受ける API のサーバー
+type Server struct {
+	backend Backend
+	token   string
+	srv     *http.Server
+}
+
+// NewServer は 127.0.0.1:port で待ち受ける Server を生成する
+func NewServer(backend Backend, port int, token string) *Server {
+	s := &Server{backend: backend, token: token}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/status", s.handleStatus)
+	mux.HandleFunc("POST /v1/pause", s.handlePause)
+	mux.HandleFunc("POST /v1/resume", s.handleResume)
+	mux.HandleFunc("POST /v1/enqueue", s.handleEnqueue)
+	mux.HandleFunc("POST /v1/dead-letters/retry", s.handleRetryDeadLetters)
+	mux.HandleFunc("POST /v1/shutdown", s.handleShutdown)
+	s.srv = &http.Server{
+		Addr:              net.JoinHostPort("127.0.0.1", strconv.Itoa(port)),
+		Handler:           s.authorize(mux),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return s
+}
+
+// Addr は待ち受けるアドレスを返す
+func (s *Server) Addr() string {
+	return s.srv.Addr
+}
+
+// Start はポートを開いて、別の goroutine でリクエストの処理を始める
+// ポートを開けない場合はエラーを返す
+func (s *Server) Start() error {
+	ln, err := net.Listen("tcp", s.srv.Addr)
+	if err != nil {
+		return err
+	}
+	logger.Info("API server started", "addr", ln.Addr().String())
+	go func() {
+		if err := s.srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("API server stopped", "error", err)
+		}
+	}()
+	return nil
+}
+
+// Shutdown は処理中のリクエストを待ってからサーバーを停止する
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
+}
+
+// authorize はトークンが一致しないリクエストを拒否する
+func (s *Server) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			logger.Warn("rejected API request", "method", r.Method, "path", r.URL.Path, "remote", r.RemoteAddr)
+			writeError(w, http.StatusUnauthorized, errors.New("invalid or missing token"))
+			return
+		}
+		logger.Debug("API request", "method", r.Method, "path", r.URL.Path)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.backend.Status())
+}
+
+// PauseRequest は POST /v1/pause のリクエスト
+// ボディは省略できる
+type PauseRequest struct {
+	// Duration は自動で再開するまでの時間 ("30m" など)
+	// 空の場合は設定ファイルの pause_auto_resume に従う
+	Duration string `json:"duration,omitempty"`
+}
+
+func (s *Server) handlePause(w http.ResponseWriter, r *http.Request) {
+	var req PauseRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody)).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	var after time.Duration
+	if req.Duration != "" {
+		d, err := time.ParseDuration(req.Duration)
+		if err != nil || d <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("duration must be a positive duration such as 30m: %q", req.Duration))
+			return
+		}
+		after = d
+	}
+	s.backend.Pause(after)
+	writeJSON(w, http.StatusOK, s.backend.Status())
+}
+
+func (s *Server) handleResume(w http.ResponseWriter, r *http.Request) {
+	s.backend.Resume()
+	writeJSON(w, http.StatusOK, s.backend.Status())
+}
+
+// EnqueueRequest は POST /v1/enqueue のリクエスト
+type EnqueueRequest struct {
+	// Path はアップロードする画像の絶対パス
+	Path string `json:"path"`
+}
+
+func (s *Server) handleEnqueue(w http.ResponseWriter, r *http.Request) {
+	var req EnqueueRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	// 常駐プロセスとクライアントで作業ディレクトリが違うため、相対パスは受け付けない
+	if !filepath.IsAbs(req.Path) {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("path must be absolute: %q", req.Path))
+		return
+	}
+	if err := s.backend.Enqueue(filepath.Clean(req.Path)); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, uploader.ErrQueueFull) {
+			status = http.StatusServiceUnavailable
+		}
+		writeError(w, status, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, EnqueueRequest{Path: filepath.Clean(req.Path)})
+}
+
+// RetryResponse は POST /v1/dead-letters/retry のレスポンス
+type RetryResponse struct {
+	// Queued はキューに追加し直した画像の数
+	Queued int `json:"queued"`
+}
+
+func (s *Server) handleRetryDeadLetters(w http.ResponseWriter, r *http.Request) {
+	n, err := s.backend.RetryDeadLetters()
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, uploader.ErrQueueFull) {
+			status = http.StatusServiceUnavailable
+		}
+		writeError(w, status, fmt.Errorf("queued %d, then failed: %w", n, err))
+		return
+	}
+	writeJSON(w, http.StatusOK, RetryResponse{Queued: n})
+}
+
+func (s *Server) handleShutdown(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusAccepted, struct{}{})
+	// レスポンスを返してから終了を始める
+	go s.backend.Shutdown()
+}
+
+// errorResponse はエラーの場合のレスポンス
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Debug("failed to write API response", "error", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
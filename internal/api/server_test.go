@@ -0,0 +1,267 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zztkm/zgyazo/internal/uploader"
+)
+
+const testToken = "test-token"
+
+// fakeBackend は呼び出された操作を記録する Backend
+type fakeBackend struct {
+	mu          sync.Mutex
+	paused      bool
+	pauseAfter  time.Duration
+	enqueued    []string
+	enqueueErr  error
+	retried     int
+	retryErr    error
+	shutdownReq chan struct{}
+}
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{shutdownReq: make(chan struct{}, 1)}
+}
+
+func (b *fakeBackend) Status() Status {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := Status{Version: "test", PID: 1234}
+	s.QueueLength = len(b.enqueued)
+	if b.paused {
+		s.PauseReasons = []uploader.PauseReason{uploader.PauseUser}
+	}
+	return s
+}
+
+func (b *fakeBackend) Pause(after time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.paused = true
+	b.pauseAfter = after
+}
+
+func (b *fakeBackend) Resume() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.paused = false
+}
+
+func (b *fakeBackend) Enqueue(filePath string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.enqueueErr != nil {
+		return b.enqueueErr
+	}
+	b.enqueued = append(b.enqueued, filePath)
+	return nil
+}
+
+func (b *fakeBackend) RetryDeadLetters() (int, error) {
+	return b.retried, b.retryErr
+}
+
+func (b *fakeBackend) Shutdown() {
+	b.shutdownReq <- struct{}{}
+}
+
+// startTestServer は backend の API を httptest で起動し、token で呼び出す Client を返す
+func startTestServer(t *testing.T, backend Backend, token string) (*httptest.Server, *Client) {
+	t.Helper()
+	ts := httptest.NewServer(NewServer(backend, 0, testToken).srv.Handler)
+	t.Cleanup(ts.Close)
+	return ts, &Client{baseURL: ts.URL, token: token, httpClient: ts.Client()}
+}
+
+// statusCode は err が StatusError の場合、そのステータスコードを返す
+func statusCode(err error) int {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode
+	}
+	return 0
+}
+
+func TestAuthorize(t *testing.T) {
+	backend := newFakeBackend()
+	ts, _ := startTestServer(t, backend, testToken)
+
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{name: "missing", header: "", want: http.StatusUnauthorized},
+		{name: "wrong token", header: "Bearer wrong-token", want: http.StatusUnauthorized},
+		{name: "prefix of the token", header: "Bearer " + testToken[:4], want: http.StatusUnauthorized},
+		{name: "not bearer", header: "Basic " + testToken, want: http.StatusUnauthorized},
+		{name: "valid", header: "Bearer " + testToken, want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, ts.URL+"/v1/status", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			resp, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+
+	// 認証に失敗した操作は実行しない
+	_, client := startTestServer(t, backend, "wrong-token")
+	ctx := context.Background()
+	if _, err := client.Pause(ctx, 0); statusCode(err) != http.StatusUnauthorized {
+		t.Errorf("Pause with a wrong token: err = %v, want 401", err)
+	}
+	if err := client.Shutdown(ctx); statusCode(err) != http.StatusUnauthorized {
+		t.Errorf("Shutdown with a wrong token: err = %v, want 401", err)
+	}
+	if backend.Status().PauseReasons != nil || len(backend.shutdownReq) != 0 {
+		t.Error("backend was called for an unauthorized request")
+	}
+}
+
+func TestStatusAndPause(t *testing.T) {
+	backend := newFakeBackend()
+	_, client := startTestServer(t, backend, testToken)
+	ctx := context.Background()
+
+	status, err := client.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Version != "test" || status.PID != 1234 || len(status.PauseReasons) != 0 {
+		t.Errorf("Status = %+v", status)
+	}
+
+	if status, err = client.Pause(ctx, 30*time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(status.PauseReasons, []uploader.PauseReason{uploader.PauseUser}) {
+		t.Errorf("PauseReasons after Pause = %q", status.PauseReasons)
+	}
+	if backend.pauseAfter != 30*time.Minute {
+		t.Errorf("paused for %v, want 30m", backend.pauseAfter)
+	}
+	// 時間を指定しない場合は 0 を渡し、設定ファイルに従わせる
+	if _, err := client.Pause(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	if backend.pauseAfter != 0 {
+		t.Errorf("paused for %v without a duration, want 0", backend.pauseAfter)
+	}
+
+	if status, err = client.Resume(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(status.PauseReasons) != 0 {
+		t.Errorf("PauseReasons after Resume = %q", status.PauseReasons)
+	}
+}
+
+func TestPauseInvalidDuration(t *testing.T) {
+	backend := newFakeBackend()
+	_, client := startTestServer(t, backend, testToken)
+	for _, body := range []any{PauseRequest{Duration: "soon"}, PauseRequest{Duration: "-1m"}, "not an object"} {
+		if err := client.do(context.Background(), http.MethodPost, "/v1/pause", body, nil); statusCode(err) != http.StatusBadRequest {
+			t.Errorf("pause with %+v: err = %v, want 400", body, err)
+		}
+	}
+	if backend.paused {
+		t.Error("paused with an invalid request")
+	}
+}
+
+func TestEnqueue(t *testing.T) {
+	backend := newFakeBackend()
+	_, client := startTestServer(t, backend, testToken)
+	ctx := context.Background()
+
+	path := filepath.Join(t.TempDir(), "shot.png")
+	if err := client.Enqueue(ctx, path+string(filepath.Separator)); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(backend.enqueued, []string{path}) {
+		t.Errorf("enqueued %q, want %q", backend.enqueued, path)
+	}
+
+	// 常駐プロセスとは作業ディレクトリが違うため、相対パスは受け付けない
+	err := client.Enqueue(ctx, "shot.png")
+	if statusCode(err) != http.StatusBadRequest || !strings.Contains(err.Error(), "absolute") {
+		t.Errorf("Enqueue with a relative path: err = %v, want 400", err)
+	}
+
+	backend.enqueueErr = uploader.ErrQueueFull
+	if err := client.Enqueue(ctx, path); statusCode(err) != http.StatusServiceUnavailable {
+		t.Errorf("Enqueue with a full queue: err = %v, want 503", err)
+	}
+	backend.enqueueErr = errors.New("not an image")
+	if err := client.Enqueue(ctx, path); statusCode(err) != http.StatusBadRequest {
+		t.Errorf("Enqueue of an invalid file: err = %v, want 400", err)
+	}
+}
+
+func TestRetryDeadLetters(t *testing.T) {
+	backend := newFakeBackend()
+	_, client := startTestServer(t, backend, testToken)
+	ctx := context.Background()
+
+	backend.retried = 2
+	if n, err := client.RetryDeadLetters(ctx); err != nil || n != 2 {
+		t.Errorf("RetryDeadLetters = %d, %v, want 2", n, err)
+	}
+
+	backend.retryErr = uploader.ErrQueueFull
+	_, err := client.RetryDeadLetters(ctx)
+	if statusCode(err) != http.StatusServiceUnavailable || !strings.Contains(err.Error(), "queued 2") {
+		t.Errorf("RetryDeadLetters with a full queue: err = %v, want 503", err)
+	}
+	backend.retryErr = errors.New("disk error")
+	if _, err := client.RetryDeadLetters(ctx); statusCode(err) != http.StatusInternalServerError {
+		t.Errorf("RetryDeadLetters with an error: err = %v, want 500", err)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	backend := newFakeBackend()
+	_, client := startTestServer(t, backend, testToken)
+	if err := client.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-backend.shutdownReq:
+	case <-time.After(5 * time.Second):
+		t.Error("Shutdown was not called")
+	}
+}
+
+func TestUnknownRoute(t *testing.T) {
+	_, client := startTestServer(t, newFakeBackend(), testToken)
+	ctx := context.Background()
+	if err := client.do(ctx, http.MethodGet, "/v1/pause", nil, nil); statusCode(err) != http.StatusMethodNotAllowed {
+		t.Errorf("GET /v1/pause: err = %v, want 405", err)
+	}
+	if err := client.do(ctx, http.MethodGet, "/v1/missing", nil, nil); statusCode(err) != http.StatusNotFound {
+		t.Errorf("GET /v1/missing: err = %v, want 404", err)
+	}
+}
@@ -0,0 +1,152 @@
+// Package auth は OAuth2 の認可コードフローでアクセストークンを取得する
+//
+// ブラウザで認可したあとのリダイレクトは、127.0.0.1 で待ち受ける一時的な HTTP サーバーで受け取る
+// (RFC 8252 のループバックリダイレクト)。認可コードの横取りを防ぐため PKCE を使う
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/zztkm/zgyazo/internal/logging"
+)
+
+var logger = logging.Logger(logging.ComponentAuth)
+
+// CallbackPath はリダイレクトを受け取るパス
+const CallbackPath = "/callback"
+
+// RedirectURL は port で待ち受ける場合のリダイレクト URL を返す
+// OAuth アプリケーションの Callback URL にはこの URL を登録する
+func RedirectURL(port int) string {
+	return fmt.Sprintf("http://127.0.0.1:%d%s", port, CallbackPath)
+}
+
+// Login は認可コードフローでアクセストークンを取得する
+// 127.0.0.1 の port (0 の場合は空いているポート) でリダイレクトを待ち受け、
+// 認可ページの URL を open に渡す。conf の RedirectURL は待ち受けたポートの URL に置き換える
+// ctx がキャンセルされるまでリダイレクトを待つ
+func Login(ctx context.Context, conf *oauth2.Config, port int, open func(authURL string) error) (*oauth2.Token, error) {
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for the OAuth redirect: %w", err)
+	}
+	defer listener.Close()
+
+	c := *conf
+	c.RedirectURL = RedirectURL(listener.Addr().(*net.TCPAddr).Port)
+	state := oauth2.GenerateVerifier()
+	verifier := oauth2.GenerateVerifier()
+
+	results := make(chan callbackResult, 1)
+	server := &http.Server{
+		Handler:           callbackHandler(state, results),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go server.Serve(listener)
+	defer server.Close()
+	logger.Debug("waiting for the OAuth redirect", "redirect_url", c.RedirectURL)
+
+	if err := open(c.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier))); err != nil {
+		return nil, err
+	}
+
+	var result callbackResult
+	select {
+	case result = <-results:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("authorization was not completed: %w", ctx.Err())
+	}
+	if result.err != nil {
+		return nil, result.err
+	}
+
+	token, err := c.Exchange(ctx, result.code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange the authorization code: %w", err)
+	}
+	return token, nil
+}
+
+// callbackResult はリダイレクトで受け取った認可コードかエラー
+type callbackResult struct {
+	code string
+	err  error
+}
+
+// callbackHandler はリダイレクトを受け取り、結果を results に送る
+// 最初のリダイレクトだけを使い、state が一致しないものは無視する
+func callbackHandler(state string, results chan<- callbackResult) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+CallbackPath, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("state") != state {
+			// 別のページからのリクエストで認可を中断させないよう、結果としては扱わない
+			logger.Warn("ignoring an OAuth redirect with a mismatched state")
+			writePage(w, http.StatusBadRequest, "認可に失敗しました", "state が一致しません。zgyazo login をやり直してください。")
+			return
+		}
+
+		var result callbackResult
+		switch {
+		case q.Get("error") != "":
+			result.err = fmt.Errorf("authorization failed: %s", strings.TrimSpace(q.Get("error")+" "+q.Get("error_description")))
+			writePage(w, http.StatusOK, "認可に失敗しました", result.err.Error())
+		case q.Get("code") == "":
+			result.err = errors.New("authorization failed: the redirect has no code")
+			writePage(w, http.StatusBadRequest, "認可に失敗しました", result.err.Error())
+		default:
+			result.code = q.Get("code")
+			writePage(w, http.StatusOK, "zgyazo にログインしました", "このウィンドウを閉じてターミナルに戻ってください。")
+		}
+		select {
+		case results <- result:
+		default:
+		}
+	})
+	return mux
+}
+
+// writePage はブラウザに表示する結果のページを書き込む
+func writePage(w http.ResponseWriter, status int, title, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\"><title>%[1]s</title></head><body><h1>%[1]s</h1><p>%[2]s</p></body></html>\n",
+		html.EscapeString(title), html.EscapeString(message))
+}
+
+// Revoke は RFC 7009 のトークン失効エンドポイント revokeURL に token を失効させる
+// クライアントの認証には conf の ClientID と ClientSecret を使う
+func Revoke(ctx context.Context, revokeURL string, conf *oauth2.Config, token string) error {
+	form := url.Values{
+		"token":           {token},
+		"token_type_hint": {"access_token"},
+		"client_id":       {conf.ClientID},
+	}
+	if conf.ClientSecret != "" {
+		form.Set("client_secret", conf.ClientSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, revokeURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to revoke the token: %s", resp.Status)
+	}
+	return nil
+}
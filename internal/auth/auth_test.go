@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/zztkm/zgyazo/gyazo/gyazotest"
+)
+
+const testToken = "test-token"
+
+// tokenRecorder は fake サーバーのトークンエンドポイントに中継し、送られた code_verifier を記録する
+type tokenRecorder struct {
+	mu        sync.Mutex
+	verifiers []string
+}
+
+func (rec *tokenRecorder) handler(tokenURL string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rec.mu.Lock()
+		rec.verifiers = append(rec.verifiers, r.PostForm.Get("code_verifier"))
+		rec.mu.Unlock()
+		res, err := http.PostForm(tokenURL, r.PostForm)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer res.Body.Close()
+		w.Header().Set("Content-Type", res.Header.Get("Content-Type"))
+		w.WriteHeader(res.StatusCode)
+		io.Copy(w, res.Body)
+	})
+}
+
+func (rec *tokenRecorder) recorded() []string {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return append([]string(nil), rec.verifiers...)
+}
+
+// newTestConfig は gyazotest の OAuth エンドポイントを使う設定を返す
+// トークンエンドポイントへのリクエストは rec を経由させる
+func newTestConfig(t *testing.T) (*oauth2.Config, *tokenRecorder) {
+	t.Helper()
+	srv := gyazotest.NewServer(testToken)
+	t.Cleanup(srv.Close)
+	rec := &tokenRecorder{}
+	proxy := httptest.NewServer(rec.handler(srv.URL + "/oauth/token"))
+	t.Cleanup(proxy.Close)
+	return &oauth2.Config{
+		ClientID: "client-id",
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  srv.URL + "/oauth/authorize",
+			TokenURL: proxy.URL,
+		},
+	}, rec
+}
+
+func login(t *testing.T, conf *oauth2.Config, open func(authURL string) error) (*oauth2.Token, error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return Login(ctx, conf, 0, open)
+}
+
+// get はブラウザの代わりに url を開き、リダイレクトをたどったあとのステータスコードを返す
+func get(t *testing.T, rawURL string) int {
+	t.Helper()
+	res, err := http.Get(rawURL)
+	if err != nil {
+		t.Errorf("GET %s: %v", rawURL, err)
+		return 0
+	}
+	res.Body.Close()
+	return res.StatusCode
+}
+
+func parseAuthURL(t *testing.T, authURL string) url.Values {
+	t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Query()
+}
+
+func TestLoginSendsCodeVerifier(t *testing.T) {
+	conf, rec := newTestConfig(t)
+	var challenge string
+	token, err := login(t, conf, func(authURL string) error {
+		q := parseAuthURL(t, authURL)
+		if q.Get("code_challenge_method") != "S256" {
+			t.Errorf("code_challenge_method = %q, want S256", q.Get("code_challenge_method"))
+		}
+		challenge = q.Get("code_challenge")
+		if status := get(t, authURL); status != http.StatusOK {
+			t.Errorf("callback returned %d", status)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if token.AccessToken != testToken {
+		t.Errorf("access token = %q, want %q", token.AccessToken, testToken)
+	}
+
+	verifiers := rec.recorded()
+	if len(verifiers) != 1 || verifiers[0] == "" {
+		t.Fatalf("code_verifier sent on token exchange = %q", verifiers)
+	}
+	sum := sha256.Sum256([]byte(verifiers[0]))
+	if got := base64.RawURLEncoding.EncodeToString(sum[:]); got != challenge {
+		t.Errorf("code_verifier does not match code_challenge %q", challenge)
+	}
+}
+
+func TestLoginIgnoresMismatchedState(t *testing.T) {
+	conf, _ := newTestConfig(t)
+	token, err := login(t, conf, func(authURL string) error {
+		q := parseAuthURL(t, authURL)
+		forged := q.Get("redirect_uri") + "?" + url.Values{"state": {"forged"}, "code": {"stolen"}}.Encode()
+		if status := get(t, forged); status != http.StatusBadRequest {
+			t.Errorf("callback with a mismatched state returned %d, want 400", status)
+		}
+		// 不正なリダイレクトのあとも、正しいリダイレクトを待ち続ける
+		get(t, authURL)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if token.AccessToken != testToken {
+		t.Errorf("access token = %q, want %q", token.AccessToken, testToken)
+	}
+}
+
+func TestLoginReturnsCallbackError(t *testing.T) {
+	conf, rec := newTestConfig(t)
+	_, err := login(t, conf, func(authURL string) error {
+		q := parseAuthURL(t, authURL)
+		denied := q.Get("redirect_uri") + "?" + url.Values{
+			"state":             {q.Get("state")},
+			"error":             {"access_denied"},
+			"error_description": {"the user denied the request"},
+		}.Encode()
+		get(t, denied)
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "access_denied") || !strings.Contains(err.Error(), "the user denied the request") {
+		t.Fatalf("Login error = %v, want access_denied with its description", err)
+	}
+	if n := len(rec.recorded()); n != 0 {
+		t.Errorf("token endpoint was called %d times after an authorization error", n)
+	}
+}
+
+func TestLoginClosesListenerAfterCallback(t *testing.T) {
+	conf, _ := newTestConfig(t)
+	var redirectURI string
+	_, err := login(t, conf, func(authURL string) error {
+		redirectURI = parseAuthURL(t, authURL).Get("redirect_uri")
+		get(t, authURL)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	res, err := http.Get(redirectURI + "?state=x&code=y")
+	if err == nil {
+		res.Body.Close()
+		t.Fatalf("redirect listener is still accepting requests after login (status %d)", res.StatusCode)
+	}
+}
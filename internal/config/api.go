@@ -0,0 +1,20 @@
+package config
+
+// DefaultAPIPort は常駐プロセスの HTTP API が待ち受けるポート
+const DefaultAPIPort = 18513
+
+// API は config.json の api の内容を表現する構造体です
+// 指定した場合だけ、常駐プロセスは 127.0.0.1 で HTTP API を待ち受ける
+type API struct {
+	// 待ち受けるポート
+	// 0 の場合は DefaultAPIPort
+	Port int `json:"port,omitempty"`
+}
+
+// ListenPort は待ち受けるポートを返す
+func (a *API) ListenPort() int {
+	if a.Port == 0 {
+		return DefaultAPIPort
+	}
+	return a.Port
+}
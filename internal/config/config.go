@@ -0,0 +1,527 @@
+// Package config は zgyazo の設定ファイルを扱う
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zztkm/zgyazo/internal/platform"
+	"github.com/zztkm/zgyazo/internal/secret"
+)
+
+// defaultFileNames は設定ディレクトリで探す設定ファイルの名前 (優先順)
+var defaultFileNames = []string{"config.json", "config.toml", "config.yaml", "config.yml"}
+
+// DefaultPath は設定ファイルのパスを返す
+// 設定ディレクトリにある最初の設定ファイルを使い、どれもない場合は config.json のパスを返す
+func DefaultPath() string {
+	for _, name := range defaultFileNames {
+		path := filepath.Join(platform.ConfigDir(), name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return filepath.Join(platform.ConfigDir(), defaultFileNames[0])
+}
+
+// 出力形式 (output_format に指定する値)
+const (
+	OutputFormatURL      = "url"
+	OutputFormatJSON     = "json"
+	OutputFormatMarkdown = "markdown"
+)
+
+// 未設定の場合に使う値
+const (
+	DefaultWorkerCount   = 3
+	DefaultCaptureHotkey = "Ctrl+Shift+C"
+	DefaultUndoHotkey    = "Ctrl+Shift+U"
+
+	DefaultSwitchProfileHotkey = "Ctrl+Shift+P"
+)
+
+// maxWorkerCount は worker_count に指定できる最大値
+const maxWorkerCount = 16
+
+// Config は config.json の内容を表現する構造体です
+type Config struct {
+	// エディタの補完に使う JSON Schema の URL
+	Schema string `json:"$schema,omitempty"`
+
+	// 設定ファイルの形式のバージョン
+	// 古い形式の設定ファイルは Load で CurrentVersion に変換される
+	Version int `json:"version"`
+
+	// Gyazo API アクセストークン
+	// "env:GYAZO_TOKEN" や "keystore:gyazo" のような参照を書くと、Load で解決した値になる
+	GyazoAccessToken string `json:"gyazo_access_token"`
+
+	// 監視するディレクトリ (Snipping Tool が画像を保存するパスなど)
+	Watches []Watch `json:"watches"`
+
+	// zgyazo upload の結果の出力形式 ("url", "json", "markdown")
+	// 空の場合は "url"
+	OutputFormat string `json:"output_format"`
+
+	// ログレベル ("debug", "info", "warn", "error")
+	// 空の場合は "info"
+	LogLevel string `json:"log_level"`
+
+	// ログの形式 ("text", "json")
+	// 空の場合は "text"
+	LogFormat string `json:"log_format,omitempty"`
+
+	// コンポーネントごとのログレベル ("uploader": "debug" など)
+	// 指定しないコンポーネントは log_level に従う
+	LogLevels map[string]string `json:"log_levels,omitempty"`
+
+	// ログのホームディレクトリのパスを "~" に置き換えるかどうか
+	// トークンと Authorization ヘッダーはこの設定にかかわらず伏せる
+	LogRedactHome bool `json:"log_redact_home,omitempty"`
+
+	// ログファイルのローテーション
+	LogFile LogFile `json:"log_file"`
+
+	// Gyazo API のエンドポイント
+	// Gyazo 互換のサーバーを使う場合に指定する。Upload API もこの URL に送信する
+	// 空の場合は Gyazo の API を使う
+	Endpoint string `json:"endpoint"`
+
+	// アップロードした画像の公開範囲 ("anyone", "only_me")
+	// 空の場合は "anyone"
+	AccessPolicy string `json:"access_policy,omitempty"`
+
+	// 使うプロファイルの名前
+	// 空の場合はプロファイルを使わず、トップレベルの設定を使う
+	Profile string `json:"profile,omitempty"`
+
+	// 名前付きのプロファイル
+	// プロファイルの項目はトップレベルの設定を上書きする
+	Profiles map[string]Profile `json:"profiles,omitempty"`
+
+	// 同時にアップロードするワーカーの数
+	// 0 の場合は DefaultWorkerCount
+	WorkerCount int `json:"worker_count,omitempty"`
+
+	// ホットキーの割り当て
+	Hotkeys Hotkeys `json:"hotkeys"`
+
+	// ホットキーや zgyazo ctl pause などで一時停止したとき、自動で再開するまでの時間 ("30m" など)
+	// 空の場合は再開するまで一時停止したままにする
+	PauseAutoResume string `json:"pause_auto_resume,omitempty"`
+
+	// zgyazo login で使う OAuth アプリケーション
+	OAuth *OAuth `json:"oauth,omitempty"`
+
+	// 常駐プロセスの状態の確認と操作に使う HTTP API
+	// 指定しない場合は待ち受けない
+	API *API `json:"api,omitempty"`
+
+	// Prometheus 形式のメトリクスを公開する設定
+	// 指定しない場合は公開しない
+	Metrics *Metrics `json:"metrics,omitempty"`
+
+	// base は WithProfile でプロファイルを反映する前の設定
+	base *Config
+
+	// unknownKeys は設定ファイルに書かれていた未知のキー
+	// 読み込みは続け、Validate でまとめて報告する
+	unknownKeys []string
+}
+
+// Watch は config.json の watches の要素を表現する構造体です
+type Watch struct {
+	// 監視するディレクトリのパス
+	Path string `json:"path"`
+
+	// このディレクトリの画像をアップロードするプロファイル
+	// 空の場合は使用中のプロファイルでアップロードする
+	Profile string `json:"profile,omitempty"`
+}
+
+// WatchPaths は監視するディレクトリのパスの一覧を返す
+func (c *Config) WatchPaths() []string {
+	paths := make([]string, len(c.Watches))
+	for i, w := range c.Watches {
+		paths[i] = w.Path
+	}
+	return paths
+}
+
+// Hotkeys は config.json の hotkeys の内容を表現する構造体です
+// キーは "Ctrl+Shift+C" のように + でつないで指定する
+type Hotkeys struct {
+	// キャプチャツールを起動するホットキー
+	// 空の場合は DefaultCaptureHotkey
+	Capture string `json:"capture,omitempty"`
+
+	// 直前のアップロードを取り消すホットキー
+	// 空の場合は DefaultUndoHotkey
+	Undo string `json:"undo,omitempty"`
+
+	// 使うプロファイルを切り替えるホットキー
+	// プロファイルがある場合だけ登録する。空の場合は DefaultSwitchProfileHotkey
+	SwitchProfile string `json:"switch_profile,omitempty"`
+
+	// アップロードの一時停止と再開を切り替えるホットキー
+	// 空の場合は登録しない
+	Pause string `json:"pause,omitempty"`
+}
+
+// CaptureKeys はキャプチャツールを起動するホットキーを返す
+func (h Hotkeys) CaptureKeys() string {
+	if h.Capture == "" {
+		return DefaultCaptureHotkey
+	}
+	return h.Capture
+}
+
+// UndoKeys は直前のアップロードを取り消すホットキーを返す
+func (h Hotkeys) UndoKeys() string {
+	if h.Undo == "" {
+		return DefaultUndoHotkey
+	}
+	return h.Undo
+}
+
+// SwitchProfileKeys はプロファイルを切り替えるホットキーを返す
+func (h Hotkeys) SwitchProfileKeys() string {
+	if h.SwitchProfile == "" {
+		return DefaultSwitchProfileHotkey
+	}
+	return h.SwitchProfile
+}
+
+// PauseKeys は一時停止と再開を切り替えるホットキーを返す
+// 登録しない場合は空を返す
+func (h Hotkeys) PauseKeys() string {
+	return h.Pause
+}
+
+// AutoResumeAfter は一時停止してから自動で再開するまでの時間を返す
+// 自動で再開しない場合や、時間として読めない場合は 0 を返す
+func (c *Config) AutoResumeAfter() time.Duration {
+	if c.PauseAutoResume == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(c.PauseAutoResume)
+	if err != nil || d < 0 {
+		return 0
+	}
+	return d
+}
+
+// Workers はアップロードするワーカーの数を返す
+func (c *Config) Workers() int {
+	if c.WorkerCount == 0 {
+		return DefaultWorkerCount
+	}
+	return c.WorkerCount
+}
+
+// Load は path の設定ファイルを読み込む
+// 拡張子が .toml の場合は TOML、.yaml か .yml の場合は YAML、それ以外は JSON として読み込む
+// 空のファイルはすべての項目が未設定の設定として扱う
+// 古い形式の設定ファイルは元のファイルをバックアップしてから現在の形式に書き換える
+// トークンなどに書かれた参照は解決した値になる
+func Load(path string) (*Config, error) {
+	config, err := LoadUnresolved(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.resolveSecrets(path); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// LoadUnresolved は Load と同じく設定ファイルを読み込むが、参照を解決しない
+// zgyazo login のように、まだ解決できない参照を含む設定ファイルを扱う場合に使う
+func LoadUnresolved(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := Config{Version: CurrentVersion}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return &config, nil
+	}
+	f := formatOf(path)
+	if data, err = toJSON(f, data); err != nil {
+		return nil, decodeFormatError(path, f, err)
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, decodeError(path, err)
+	}
+	from, err := rawVersion(raw)
+	if err != nil {
+		return nil, &ValidationError{Path: path, Problems: []Problem{{Field: "version", Message: err.Error()}}}
+	}
+	if from != CurrentVersion {
+		if data, err = migrate(data, from); err != nil {
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &typeErr) {
+				return nil, decodeError(path, err)
+			}
+			return nil, fmt.Errorf("%s: failed to migrate config from version %d: %w", path, from, err)
+		}
+		// 書き込めなくても変換した設定で動作はできるので、警告だけにする
+		// TOML と YAML は書き換えるとコメントが消えてしまうため、変換はメモリ上だけにする
+		if f != formatJSON {
+			logger.Warn("config uses an old format version; update it (see 'zgyazo config show')", "file", path, "version", from, "current_version", CurrentVersion)
+		} else if err := saveMigrated(path, from, data); err != nil {
+			logger.Warn("failed to save migrated config", "file", path, "error", err)
+		} else {
+			logger.Info("migrated config", "file", path, "from", from, "to", CurrentVersion)
+		}
+	}
+
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, decodeError(path, err)
+	}
+
+	// 未知のキーを探す
+	// DisallowUnknownFields では最初の 1 つしか分からないため、キーの一覧と比較する
+	// 変換前のキーと比べるので、元のバージョンの構造体のキーを使う
+	config.unknownKeys = findUnknownKeys(raw, configTypes[from-1], "")
+	sort.Strings(config.unknownKeys)
+	return &config, nil
+}
+
+// resolveSecrets はトークンと OAuth のクライアントシークレットに書かれた参照 (env:, file:, cmd:, keystore:) を解決する
+// 解決できなかった参照はまとめて *ValidationError として返す
+func (c *Config) resolveSecrets(path string) error {
+	var problems []Problem
+	resolve := func(field string, value *string) {
+		resolved, err := secret.Resolve(*value)
+		if err != nil {
+			problems = append(problems, Problem{Field: field, Message: fmt.Sprintf("cannot resolve %q: %v", *value, err)})
+			return
+		}
+		*value = resolved
+	}
+
+	resolve("gyazo_access_token", &c.GyazoAccessToken)
+	for _, name := range c.ProfileNames() {
+		p := c.Profiles[name]
+		resolve("profiles."+name+".gyazo_access_token", &p.GyazoAccessToken)
+		c.Profiles[name] = p
+	}
+	if c.OAuth != nil {
+		resolve("oauth.client_secret", &c.OAuth.ClientSecret)
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Path: path, Problems: problems}
+}
+
+// Secrets はトークンとクライアントシークレットの値を返す
+// ログや出力から伏せるために使う。Load で読み込んだ設定では参照を解決した値になる
+func (c *Config) Secrets() []string {
+	var secrets []string
+	add := func(value string) {
+		if value != "" && !slices.Contains(secrets, value) {
+			secrets = append(secrets, value)
+		}
+	}
+	add(c.GyazoAccessToken)
+	for _, name := range c.ProfileNames() {
+		add(c.Profiles[name].GyazoAccessToken)
+	}
+	if c.OAuth != nil {
+		add(c.OAuth.ClientSecret)
+	}
+	return secrets
+}
+
+// Masked はトークンとクライアントシークレットを伏せた設定のコピーを返す
+func (c *Config) Masked() *Config {
+	const mask = "********"
+	masked := *c
+	if masked.GyazoAccessToken != "" {
+		masked.GyazoAccessToken = mask
+	}
+	masked.Profiles = make(map[string]Profile, len(c.Profiles))
+	for name, p := range c.Profiles {
+		if p.GyazoAccessToken != "" {
+			p.GyazoAccessToken = mask
+		}
+		masked.Profiles[name] = p
+	}
+	if c.OAuth != nil && c.OAuth.ClientSecret != "" {
+		oauth := *c.OAuth
+		oauth.ClientSecret = mask
+		masked.OAuth = &oauth
+	}
+	return &masked
+}
+
+// findUnknownKeys は raw のキーのうち t の JSON のキーにないものを返す
+// 構造体とその配列・マップの項目は中のキーも調べ、"hotkeys.capture" のように prefix を付けて返す
+func findUnknownKeys(raw map[string]json.RawMessage, t reflect.Type, prefix string) []string {
+	fields := make(map[string]reflect.Type)
+	jsonFields(t, fields)
+
+	var unknown []string
+	for key, value := range raw {
+		fieldType, ok := fields[key]
+		if !ok {
+			unknown = append(unknown, prefix+key)
+			continue
+		}
+		if fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		// 型が違う場合は Unmarshal で報告済みなので、オブジェクトとその配列の場合だけ調べる
+		switch {
+		case fieldType.Kind() == reflect.Struct:
+			var nested map[string]json.RawMessage
+			if json.Unmarshal(value, &nested) == nil {
+				unknown = append(unknown, findUnknownKeys(nested, fieldType, prefix+key+".")...)
+			}
+		case fieldType.Kind() == reflect.Map && fieldType.Elem().Kind() == reflect.Struct:
+			var elems map[string]map[string]json.RawMessage
+			if json.Unmarshal(value, &elems) == nil {
+				for name, nested := range elems {
+					unknown = append(unknown, findUnknownKeys(nested, fieldType.Elem(), prefix+key+"."+name+".")...)
+				}
+			}
+		case fieldType.Kind() == reflect.Slice && fieldType.Elem().Kind() == reflect.Struct:
+			var elems []map[string]json.RawMessage
+			if json.Unmarshal(value, &elems) == nil {
+				for i, nested := range elems {
+					unknown = append(unknown, findUnknownKeys(nested, fieldType.Elem(), fmt.Sprintf("%s%s[%d].", prefix, key, i))...)
+				}
+			}
+		}
+	}
+	return unknown
+}
+
+// jsonFields は構造体 t の JSON のキーとその型を fields に追加する
+// 埋め込んだ構造体のフィールドは、JSON と同じく t のフィールドとして扱う
+func jsonFields(t reflect.Type, fields map[string]reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			jsonFields(field.Type, fields)
+			continue
+		}
+		if name != "" && name != "-" {
+			fields[name] = field.Type
+		}
+	}
+}
+
+// decodeError は JSON のデコードエラーを、どこが間違っているか分かるエラーに変換する
+func decodeError(path string, err error) error {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return fmt.Errorf("%s: invalid JSON at byte offset %d: %w", path, syntaxErr.Offset, err)
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		if typeErr.Field == "" {
+			return fmt.Errorf("%s: config must be a JSON object, got %s", path, typeErr.Value)
+		}
+		return &ValidationError{
+			Path:     path,
+			Problems: []Problem{{Field: typeErr.Field, Message: fmt.Sprintf("must be %s, got %s", typeErr.Type, typeErr.Value)}},
+		}
+	}
+	return fmt.Errorf("%s: %w", path, err)
+}
+
+// Save は設定を path に書き込む
+// 形式は Load と同じく拡張子で決める
+// トークンを含むため、ファイルは所有者だけが読み書きできるパーミッションで作成する
+func (c *Config) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if data, err = fromJSON(formatOf(path), data); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return writeFile(path, data)
+}
+
+// ErrUnsupportedFormat は設定ファイルの形式が書き換えに対応していないことを表す
+var ErrUnsupportedFormat = errors.New("only JSON config files can be updated automatically")
+
+// SetAccessToken は path の設定ファイルの gyazo_access_token を value に書き換える
+// profile が空でない場合はそのプロファイルの gyazo_access_token を書き換える
+// ほかの項目に書かれた参照を解決した値で上書きしないよう、Save ではなくキーだけを書き換える
+// ファイルがない場合は value だけを設定したファイルを作成する
+// TOML と YAML は書き換えるとコメントが消えてしまうため、ErrUnsupportedFormat を返す
+func SetAccessToken(path, profile, value string) error {
+	if formatOf(path) != formatJSON {
+		return ErrUnsupportedFormat
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	raw := map[string]json.RawMessage{}
+	if len(bytes.TrimSpace(data)) == 0 {
+		raw["$schema"], _ = json.Marshal(SchemaURL)
+		raw["version"], _ = json.Marshal(CurrentVersion)
+	} else if err := json.Unmarshal(data, &raw); err != nil {
+		return decodeError(path, err)
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if profile == "" {
+		raw["gyazo_access_token"] = encoded
+	} else {
+		var profiles map[string]map[string]json.RawMessage
+		if err := json.Unmarshal(raw["profiles"], &profiles); err != nil || profiles[profile] == nil {
+			return fmt.Errorf("%s: unknown profile %q", path, profile)
+		}
+		profiles[profile]["gyazo_access_token"] = encoded
+		if raw["profiles"], err = json.Marshal(profiles); err != nil {
+			return err
+		}
+	}
+
+	if data, err = json.MarshalIndent(raw, "", "  "); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return writeFile(path, append(data, '\n'))
+}
+
+// writeFile は data を path に書き込む
+// 書き込み中に終了しても設定ファイルが壊れないように、一時ファイルに書いてから置き換える
+func writeFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
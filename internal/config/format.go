@@ -0,0 +1,142 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// format は設定ファイルの形式
+type format int
+
+const (
+	formatJSON format = iota
+	formatTOML
+	formatYAML
+)
+
+func (f format) String() string {
+	switch f {
+	case formatTOML:
+		return "TOML"
+	case formatYAML:
+		return "YAML"
+	}
+	return "JSON"
+}
+
+// formatOf は拡張子から設定ファイルの形式を判定する
+// .toml と .yaml, .yml 以外は JSON として扱う
+func formatOf(path string) format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		return formatTOML
+	case ".yaml", ".yml":
+		return formatYAML
+	}
+	return formatJSON
+}
+
+// toJSON は TOML や YAML の設定ファイルの内容を JSON に変換する
+// どの形式も同じ JSON のキーで読み込むことで、未知のキーの検出や検証を共通にする
+func toJSON(f format, data []byte) ([]byte, error) {
+	var raw map[string]any
+	switch f {
+	case formatTOML:
+		if err := toml.Unmarshal(data, &raw); err != nil {
+			return nil, err
+		}
+	case formatYAML:
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return nil, err
+		}
+	default:
+		return data, nil
+	}
+	if raw == nil {
+		// 空の YAML
+		raw = map[string]any{}
+	}
+	return json.Marshal(raw)
+}
+
+// fromJSON は JSON の設定を f の形式に変換する
+func fromJSON(f format, data []byte) ([]byte, error) {
+	if f == formatJSON {
+		return data, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var raw map[string]any
+	if err := dec.Decode(&raw); err != nil {
+		return nil, err
+	}
+	normalizeNumbers(raw)
+
+	// TOML と YAML ではエディタが "$schema" キーではなくコメントでスキーマを指定する
+	var buf bytes.Buffer
+	schema, _ := raw["$schema"].(string)
+	delete(raw, "$schema")
+	switch f {
+	case formatTOML:
+		if schema != "" {
+			// Taplo (Even Better TOML) の形式
+			fmt.Fprintf(&buf, "#:schema %s\n\n", schema)
+		}
+		if err := toml.NewEncoder(&buf).Encode(raw); err != nil {
+			return nil, err
+		}
+	case formatYAML:
+		if schema != "" {
+			// YAML Language Server の形式
+			fmt.Fprintf(&buf, "# yaml-language-server: $schema=%s\n", schema)
+		}
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(raw); err != nil {
+			return nil, err
+		}
+		if err := enc.Close(); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// normalizeNumbers は json.Number を整数か浮動小数点数に置き換える
+// そのままだと TOML や YAML では文字列として書き込まれてしまう
+func normalizeNumbers(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			v[key] = normalizeNumbers(value)
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = normalizeNumbers(value)
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		if n, err := v.Float64(); err == nil {
+			return n
+		}
+	}
+	return v
+}
+
+// decodeFormatError は TOML や YAML の構文エラーを、どこが間違っているか分かるエラーに変換する
+func decodeFormatError(path string, f format, err error) error {
+	var parseErr toml.ParseError
+	if errors.As(err, &parseErr) {
+		return fmt.Errorf("%s: invalid TOML at line %d: %s", path, parseErr.Position.Line, parseErr.Message)
+	}
+	return fmt.Errorf("%s: invalid %s: %w", path, f, err)
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// formatFixtures は testdata に同じ内容で用意した各形式の設定ファイルの拡張子
+var formatFixtures = []string{".json", ".toml", ".yaml"}
+
+// loadFixture は testdata の name を t.TempDir() にコピーして読み込む
+// 古い形式の設定ファイルを書き換えても testdata が変わらないようにする
+func loadFixture(t *testing.T, name string) *Config {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadUnresolved(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestLoadFormats(t *testing.T) {
+	want := loadFixture(t, "full.json")
+	if len(want.Watches) != 2 || want.Profiles["work"].GyazoAccessToken != "keystore:work" ||
+		want.OAuth == nil || want.API == nil || want.Metrics == nil || !want.LogFile.Compress {
+		t.Fatalf("full.json was not fully loaded: %+v", want)
+	}
+	if len(want.unknownKeys) != 0 {
+		t.Errorf("full.json has unknown keys %q", want.unknownKeys)
+	}
+	for _, ext := range formatFixtures[1:] {
+		t.Run(ext, func(t *testing.T) {
+			if got := loadFixture(t, "full"+ext); !reflect.DeepEqual(got, want) {
+				t.Errorf("loaded config differs from full.json\n got: %+v\nwant: %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestLoadFormatsUnknownKeys(t *testing.T) {
+	want := []string{"hotkeys.captuer", "log_levl", "profiles.work.gyazo_acces_token", "watches[0].profle"}
+	for _, ext := range formatFixtures {
+		t.Run(ext, func(t *testing.T) {
+			cfg := loadFixture(t, "unknown"+ext)
+			if !slices.Equal(cfg.unknownKeys, want) {
+				t.Errorf("unknown keys = %q, want %q", cfg.unknownKeys, want)
+			}
+		})
+	}
+}
+
+func TestSaveFormatsRoundTrip(t *testing.T) {
+	want := loadFixture(t, "full.json")
+	for _, ext := range formatFixtures {
+		t.Run(ext, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config"+ext)
+			if err := want.Save(path); err != nil {
+				t.Fatal(err)
+			}
+			got, err := LoadUnresolved(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("saved config differs after loading\n got: %+v\nwant: %+v", got, want)
+			}
+		})
+	}
+}
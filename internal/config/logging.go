@@ -0,0 +1,78 @@
+package config
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/zztkm/zgyazo/internal/logging"
+)
+
+var logger = logging.Logger(logging.ComponentConfig)
+
+// LogOptions は log_level, log_format, log_levels, log_redact_home をログの出力方法に変換する
+// トークンとクライアントシークレットはログで伏せる
+func (c *Config) LogOptions() (logging.Options, error) {
+	level, err := logging.ParseLevel(c.LogLevel)
+	if err != nil {
+		return logging.Options{}, err
+	}
+	format, err := logging.ParseFormat(c.LogFormat)
+	if err != nil {
+		return logging.Options{}, err
+	}
+	opts := logging.Options{Level: level, Format: format, Secrets: c.Secrets(), RedactHome: c.LogRedactHome}
+	for component, name := range c.LogLevels {
+		level, err := logging.ParseLevel(name)
+		if err != nil {
+			return logging.Options{}, err
+		}
+		if opts.ComponentLevels == nil {
+			opts.ComponentLevels = make(map[string]slog.Level)
+		}
+		opts.ComponentLevels[component] = level
+	}
+	return opts, nil
+}
+
+// LogFile は config.json の log_file の内容を表現する構造体です
+type LogFile struct {
+	// ローテーションのタイミング ("size", "daily")
+	// 空の場合は "size"
+	Rotate string `json:"rotate,omitempty"`
+
+	// ログファイルの最大サイズ (MB)
+	// 0 の場合、"size" では 10MB、"daily" ではサイズでローテーションしない
+	MaxSizeMB int `json:"max_size_mb,omitempty"`
+
+	// 残すバックアップファイルの数
+	// 0 の場合は 5
+	MaxBackups int `json:"max_backups,omitempty"`
+
+	// この日数より古いバックアップファイルは削除する
+	// 0 の場合は日数では削除しない
+	MaxAgeDays int `json:"max_age_days,omitempty"`
+
+	// バックアップファイルを gzip で圧縮するかどうか
+	Compress bool `json:"compress,omitempty"`
+}
+
+// LogRotation は log_file をログファイルのローテーションの設定に変換する
+func (c *Config) LogRotation() logging.RotateOptions {
+	opts := logging.DefaultRotateOptions()
+	f := c.LogFile
+	if f.Rotate != "" {
+		opts.Rotate = f.Rotate
+	}
+	switch {
+	case f.MaxSizeMB > 0:
+		opts.MaxSize = int64(f.MaxSizeMB) * 1024 * 1024
+	case opts.Rotate == logging.RotateDaily:
+		opts.MaxSize = 0
+	}
+	if f.MaxBackups > 0 {
+		opts.MaxBackups = f.MaxBackups
+	}
+	opts.MaxAge = time.Duration(f.MaxAgeDays) * 24 * time.Hour
+	opts.Compress = f.Compress
+	return opts
+}
@@ -0,0 +1,20 @@
+package config
+
+// DefaultMetricsPort は常駐プロセスのメトリクスを公開するポート
+const DefaultMetricsPort = 18514
+
+// Metrics は config.json の metrics の内容を表現する構造体です
+// 指定した場合だけ、常駐プロセスは 127.0.0.1 の /metrics で Prometheus 形式のメトリクスを公開する
+type Metrics struct {
+	// 待ち受けるポート
+	// 0 の場合は DefaultMetricsPort
+	Port int `json:"port,omitempty"`
+}
+
+// ListenPort は待ち受けるポートを返す
+func (m *Metrics) ListenPort() int {
+	if m.Port == 0 {
+		return DefaultMetricsPort
+	}
+	return m.Port
+}
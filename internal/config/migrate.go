@@ -0,0 +1,96 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"time"
+)
+
+// CurrentVersion は現在の設定ファイルの形式のバージョン
+// 形式を変えるときはバージョンを上げ、migrations に変換処理を追加する
+const CurrentVersion = 2
+
+// migrations[i] はバージョン i+1 の設定ファイルの内容をバージョン i+2 の内容に変換する
+// 各変換処理はそのバージョンの構造体に読み込んで書き出すので、キーは構造体のフィールドの順に並ぶ
+var migrations = []func(data []byte) ([]byte, error){
+	migrateV1ToV2,
+}
+
+// configTypes[i] はバージョン i+1 の設定ファイルを読み込む構造体の型
+// 未知のキーを探すときに使う
+var configTypes = []reflect.Type{
+	reflect.TypeOf(configV1{}),
+	reflect.TypeOf(Config{}),
+}
+
+// rawVersion は設定ファイルの形式のバージョンを返す
+// version キーがない設定ファイルはバージョン 1 として扱う
+func rawVersion(raw map[string]json.RawMessage) (int, error) {
+	value, ok := raw["version"]
+	if !ok {
+		return 1, nil
+	}
+	var version int
+	if err := json.Unmarshal(value, &version); err != nil || version < 1 {
+		return 0, fmt.Errorf("must be a positive integer, got %s", value)
+	}
+	if version > CurrentVersion {
+		return 0, fmt.Errorf("version %d is newer than this zgyazo supports (%d); upgrade zgyazo", version, CurrentVersion)
+	}
+	return version, nil
+}
+
+// migrate は バージョン from の設定を順に変換し、現在の形式の設定ファイルの内容を返す
+// 未知のキーは変換後の内容には残らない (読み込んだときの Validate で報告し、バックアップに残る)
+func migrate(data []byte, from int) ([]byte, error) {
+	for version := from; version < CurrentVersion; version++ {
+		var err error
+		if data, err = migrations[version-1](data); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// saveMigrated は元の設定ファイルをバックアップしてから、変換した設定を書き込む
+// バックアップは "config.json.v1-20060102-150405.bak" のように元のバージョンと日時を付けた名前にする
+func saveMigrated(path string, from int, data []byte) error {
+	original, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	backup := fmt.Sprintf("%s.v%d-%s.bak", path, from, time.Now().Format("20060102-150405"))
+	if err := os.WriteFile(backup, original, 0600); err != nil {
+		return fmt.Errorf("failed to back up config: %w", err)
+	}
+	logger.Info("backed up config", "file", backup)
+	return writeFile(path, data)
+}
+
+// configV1 はバージョン 1 の設定ファイル
+// 監視するディレクトリは watches ではなく snipping_tool_save_path に 1 つだけ書く
+type configV1 struct {
+	Config
+	SnippingToolSavePath string `json:"snipping_tool_save_path,omitempty"`
+}
+
+// migrateV1ToV2 は監視するディレクトリを snipping_tool_save_path から watches に移す
+// バージョン 2 からは複数のディレクトリを監視できる
+func migrateV1ToV2(data []byte) ([]byte, error) {
+	var v1 configV1
+	if err := json.Unmarshal(data, &v1); err != nil {
+		return nil, err
+	}
+	config := v1.Config
+	config.Version = 2
+	if v1.SnippingToolSavePath != "" {
+		config.Watches = []Watch{{Path: v1.SnippingToolSavePath}}
+	}
+	data, err := json.MarshalIndent(&config, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
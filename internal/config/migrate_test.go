@@ -0,0 +1,126 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMigrateV1ToV2(t *testing.T) {
+	original := `{
+  "gyazo_access_token": "token",
+  "snipping_tool_save_path": "{{dir}}",
+  "output_format": "markdown",
+  "log_levl": "debug"
+}
+`
+	path := writeConfig(t, "config.json", original)
+	dir := filepath.Dir(path)
+	original = strings.ReplaceAll(original, "{{dir}}", filepath.ToSlash(dir))
+
+	cfg, err := LoadUnresolved(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Version != CurrentVersion {
+		t.Errorf("Version = %d, want %d", cfg.Version, CurrentVersion)
+	}
+	if want := []Watch{{Path: filepath.ToSlash(dir)}}; !slices.Equal(cfg.Watches, want) {
+		t.Errorf("Watches = %+v, want %+v", cfg.Watches, want)
+	}
+	if cfg.GyazoAccessToken != "token" || cfg.OutputFormat != OutputFormatMarkdown {
+		t.Errorf("other settings were not kept: %+v", cfg)
+	}
+	// 移したキーは未知のキーとして報告しない
+	if got := problemFields(t, cfg.Validate(path)); !slices.Equal(got, []string{"log_levl"}) {
+		t.Errorf("problem fields = %q, want [log_levl]", got)
+	}
+
+	// 元のファイルはバージョン付きの名前でバックアップする
+	backups, err := filepath.Glob(filepath.Join(dir, "config.json.v1-*.bak"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 1 {
+		t.Fatalf("backups = %q, want one file", backups)
+	}
+	if data, err := os.ReadFile(backups[0]); err != nil || string(data) != original {
+		t.Errorf("backup = %q, %v, want the original config", data, err)
+	}
+
+	// 書き換えたファイルは現在の形式で、キーは構造体のフィールドの順に並ぶ
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := string(data)
+	if strings.Contains(content, "snipping_tool_save_path") {
+		t.Errorf("migrated config still has snipping_tool_save_path:\n%s", content)
+	}
+	keys := []string{`"version": 2`, `"gyazo_access_token"`, `"watches"`, `"output_format"`}
+	last := -1
+	for _, key := range keys {
+		i := strings.Index(content, key)
+		if i < 0 {
+			t.Fatalf("migrated config does not contain %s:\n%s", key, content)
+		}
+		if i < last {
+			t.Errorf("keys of the migrated config are not in the order %q:\n%s", keys, content)
+			break
+		}
+		last = i
+	}
+
+	// 書き換えた設定は変換せずにそのまま読み込める
+	again, err := LoadUnresolved(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(again.Watches, cfg.Watches) || again.OutputFormat != cfg.OutputFormat {
+		t.Errorf("reloaded config = %+v, want %+v", again, cfg)
+	}
+	if backups, _ := filepath.Glob(filepath.Join(dir, "*.bak")); len(backups) != 1 {
+		t.Errorf("backups after reloading = %q, want one file", backups)
+	}
+}
+
+func TestMigrateV1WithoutSavePath(t *testing.T) {
+	path := writeConfig(t, "config.json", `{"gyazo_access_token": "token"}`)
+	cfg, err := LoadUnresolved(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Version != CurrentVersion || len(cfg.Watches) != 0 {
+		t.Errorf("Version = %d, Watches = %+v, want %d and no watches", cfg.Version, cfg.Watches, CurrentVersion)
+	}
+}
+
+func TestMigrateInvalidType(t *testing.T) {
+	path := writeConfig(t, "config.json", `{"snipping_tool_save_path": 1}`)
+	_, err := LoadUnresolved(path)
+	if got := problemFields(t, err); !slices.Equal(got, []string{"snipping_tool_save_path"}) {
+		t.Errorf("problem fields = %q, want [snipping_tool_save_path]\n%v", got, err)
+	}
+	// 変換できなかった設定ファイルは書き換えない
+	if backups, _ := filepath.Glob(path + ".*.bak"); len(backups) != 0 {
+		t.Errorf("backups = %q, want none", backups)
+	}
+}
+
+func TestMigrateTOMLInMemory(t *testing.T) {
+	original := "gyazo_access_token = \"token\"\nsnipping_tool_save_path = \"{{dir}}\"\n"
+	path := writeConfig(t, "config.toml", original)
+	cfg, err := LoadUnresolved(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Watches) != 1 || cfg.Watches[0].Path != filepath.ToSlash(filepath.Dir(path)) {
+		t.Errorf("Watches = %+v, want the snipping_tool_save_path", cfg.Watches)
+	}
+	// TOML はコメントが消えないように書き換えない
+	if data, err := os.ReadFile(path); err != nil || strings.Contains(string(data), "watches") {
+		t.Errorf("TOML config was rewritten: %q, %v", data, err)
+	}
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"golang.org/x/oauth2"
+
+	"github.com/zztkm/zgyazo/gyazo"
+)
+
+// DefaultRedirectPort は zgyazo login がリダイレクトを待ち受けるポート
+// Gyazo は登録した Callback URL と完全に一致するリダイレクトしか許可しないため、固定のポートを使う
+const DefaultRedirectPort = 18512
+
+// OAuth は config.json の oauth の内容を表現する構造体です
+// https://gyazo.com/oauth/applications に登録したアプリケーションを指定する
+type OAuth struct {
+	// アプリケーションの Client ID
+	ClientID string `json:"client_id"`
+
+	// アプリケーションの Client Secret
+	// gyazo_access_token と同じく "keystore:NAME" のような参照を書ける
+	ClientSecret string `json:"client_secret,omitempty"`
+
+	// 認可エンドポイント
+	// 空の場合は Gyazo のエンドポイント
+	AuthorizeURL string `json:"authorize_url,omitempty"`
+
+	// トークンエンドポイント
+	// 空の場合は Gyazo のエンドポイント
+	TokenURL string `json:"token_url,omitempty"`
+
+	// トークン失効エンドポイント (RFC 7009)
+	// 空の場合、zgyazo logout は保存したトークンを削除するだけでサーバー側では失効させない
+	RevokeURL string `json:"revoke_url,omitempty"`
+
+	// リダイレクトを待ち受けるポート
+	// 0 の場合は DefaultRedirectPort
+	RedirectPort int `json:"redirect_port,omitempty"`
+}
+
+// Port はリダイレクトを待ち受けるポートを返す
+func (o *OAuth) Port() int {
+	if o.RedirectPort == 0 {
+		return DefaultRedirectPort
+	}
+	return o.RedirectPort
+}
+
+// Config は OAuth2 のクライアントの設定を返す
+// RedirectURL はリダイレクトを待ち受けるときに設定する
+func (o *OAuth) Config() *oauth2.Config {
+	endpoint := gyazo.OAuthEndpoint
+	if o.AuthorizeURL != "" {
+		endpoint.AuthURL = o.AuthorizeURL
+	}
+	if o.TokenURL != "" {
+		endpoint.TokenURL = o.TokenURL
+	}
+	return &oauth2.Config{
+		ClientID:     o.ClientID,
+		ClientSecret: o.ClientSecret,
+		Endpoint:     endpoint,
+	}
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Profile は config.json の profiles の要素を表現する構造体です
+// 個人用とチーム用のように複数の Gyazo アカウントを使い分けるためのもの
+// 空の項目はトップレベルの設定を使う
+type Profile struct {
+	// Gyazo API アクセストークン
+	// トップレベルの設定と同じく参照を書ける
+	GyazoAccessToken string `json:"gyazo_access_token,omitempty"`
+
+	// Gyazo API のエンドポイント
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// アップロードした画像の公開範囲 ("anyone", "only_me")
+	AccessPolicy string `json:"access_policy,omitempty"`
+
+	// zgyazo upload の結果の出力形式 ("url", "json", "markdown")
+	OutputFormat string `json:"output_format,omitempty"`
+}
+
+// ProfileNames はプロファイルの名前を名前順に返す
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WithProfile は name のプロファイルの項目でトップレベルの設定を上書きした設定のコピーを返す
+// WithProfile で作った設定から呼び出した場合も、プロファイルを反映する前の設定を元にする
+// name が空の場合はプロファイルを使わない設定を返す
+func (c *Config) WithProfile(name string) (*Config, error) {
+	base := c
+	if c.base != nil {
+		base = c.base
+	}
+	merged := *base
+	merged.base = base
+	merged.Profile = name
+	if name == "" {
+		return &merged, nil
+	}
+
+	p, ok := base.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown profile %q (available: %s)", name, strings.Join(base.ProfileNames(), ", "))
+	}
+	if p.GyazoAccessToken != "" {
+		merged.GyazoAccessToken = p.GyazoAccessToken
+	}
+	if p.Endpoint != "" {
+		merged.Endpoint = p.Endpoint
+	}
+	if p.AccessPolicy != "" {
+		merged.AccessPolicy = p.AccessPolicy
+	}
+	if p.OutputFormat != "" {
+		merged.OutputFormat = p.OutputFormat
+	}
+	return &merged, nil
+}
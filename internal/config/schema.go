@@ -0,0 +1,13 @@
+package config
+
+import _ "embed"
+
+// SchemaURL は公開している設定ファイルの JSON Schema の URL
+// 設定ファイルの "$schema" に指定するとエディタで補完や検証ができる
+const SchemaURL = "https://raw.githubusercontent.com/zztkm/zgyazo/main/internal/config/config.schema.json"
+
+// Schema は設定ファイルの JSON Schema
+// 形式を変えたときは config.schema.json も更新すること
+//
+//go:embed config.schema.json
+var Schema []byte
@@ -0,0 +1,261 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/zztkm/zgyazo/gyazo"
+	"github.com/zztkm/zgyazo/internal/logging"
+	"github.com/zztkm/zgyazo/internal/platform"
+)
+
+// Problem は設定の問題 1 件
+type Problem struct {
+	// Field は問題のある項目の設定ファイル上のキー
+	Field string
+
+	// Message は問題の内容と直し方
+	Message string
+}
+
+func (p Problem) String() string {
+	return p.Field + ": " + p.Message
+}
+
+// ValidationError は設定の問題をまとめたエラー
+type ValidationError struct {
+	// Path は設定ファイルのパス
+	Path string
+
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %d problem(s) found", e.Path, len(e.Problems))
+	for _, p := range e.Problems {
+		b.WriteString("\n  - ")
+		b.WriteString(p.String())
+	}
+	return b.String()
+}
+
+// Validate は設定の問題をすべて調べ、問題があれば *ValidationError を返す
+// path はエラーメッセージに表示する設定ファイルのパス
+func (c *Config) Validate(path string) error {
+	var problems []Problem
+	add := func(field string, format string, args ...any) {
+		problems = append(problems, Problem{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	for _, key := range c.unknownKeys {
+		add(key, "unknown key (typo?)")
+	}
+
+	if c.GyazoAccessToken == "" {
+		field := "gyazo_access_token"
+		if c.Profile != "" {
+			field = "profiles." + c.Profile + ".gyazo_access_token"
+		}
+		add(field, "is required; run 'zgyazo login', or get a token at https://gyazo.com/api and set it here or in ZGYAZO_TOKEN")
+	}
+
+	base := c
+	if c.base != nil {
+		base = c.base
+	}
+	for _, name := range c.ProfileNames() {
+		p := c.Profiles[name]
+		prefix := "profiles." + name + "."
+		if strings.TrimSpace(name) == "" {
+			add("profiles", "profile name must not be empty")
+		}
+		if p.GyazoAccessToken == "" && base.GyazoAccessToken == "" {
+			add(prefix+"gyazo_access_token", "is required unless the top-level gyazo_access_token is set")
+		}
+		if p.Endpoint != "" && !isHTTPURL(p.Endpoint) {
+			add(prefix+"endpoint", "must be an http or https URL such as https://api.gyazo.com, got %q", p.Endpoint)
+		}
+		if !isAccessPolicy(p.AccessPolicy) {
+			add(prefix+"access_policy", "must be %q or %q, got %q", gyazo.AccessPolicyAnyone, gyazo.AccessPolicyOnlyMe, p.AccessPolicy)
+		}
+		if !isOutputFormat(p.OutputFormat) {
+			add(prefix+"output_format", "must be one of %q, %q, %q, got %q", OutputFormatURL, OutputFormatJSON, OutputFormatMarkdown, p.OutputFormat)
+		}
+	}
+
+	if len(c.Watches) == 0 {
+		add("watches", "is required; add the folder where your capture tool saves screenshots, e.g. [{\"path\": \"...\"}]")
+	}
+	seenPaths := make(map[string]string)
+	for i, w := range c.Watches {
+		field := fmt.Sprintf("watches[%d].path", i)
+		if w.Path == "" {
+			add(field, "is required")
+			continue
+		}
+		if other, ok := seenPaths[filepath.Clean(w.Path)]; ok {
+			add(field, "%s is already watched by %s", w.Path, other)
+			continue
+		}
+		seenPaths[filepath.Clean(w.Path)] = field
+		if _, ok := c.Profiles[w.Profile]; w.Profile != "" && !ok {
+			add(fmt.Sprintf("watches[%d].profile", i), "unknown profile %q", w.Profile)
+		}
+		if info, err := os.Stat(w.Path); os.IsNotExist(err) {
+			add(field, "%s does not exist; create it or fix the path", w.Path)
+		} else if err != nil {
+			add(field, "cannot access %s: %v", w.Path, err)
+		} else if !info.IsDir() {
+			add(field, "%s is not a directory", w.Path)
+		}
+	}
+
+	if !isOutputFormat(c.OutputFormat) {
+		add("output_format", "must be one of %q, %q, %q, got %q", OutputFormatURL, OutputFormatJSON, OutputFormatMarkdown, c.OutputFormat)
+	}
+
+	if !isAccessPolicy(c.AccessPolicy) {
+		add("access_policy", "must be %q or %q, got %q", gyazo.AccessPolicyAnyone, gyazo.AccessPolicyOnlyMe, c.AccessPolicy)
+	}
+
+	if _, err := logging.ParseLevel(c.LogLevel); err != nil {
+		add("log_level", "%v", err)
+	}
+	if _, err := logging.ParseFormat(c.LogFormat); err != nil {
+		add("log_format", "%v", err)
+	}
+	for _, component := range slices.Sorted(maps.Keys(c.LogLevels)) {
+		field := "log_levels." + component
+		if !logging.IsComponent(component) {
+			add(field, "unknown component %q (%s)", component, strings.Join(logging.Components, ", "))
+			continue
+		}
+		if _, err := logging.ParseLevel(c.LogLevels[component]); err != nil {
+			add(field, "%v", err)
+		}
+	}
+
+	if r := c.LogFile.Rotate; r != "" && r != logging.RotateSize && r != logging.RotateDaily {
+		add("log_file.rotate", "must be %q or %q, got %q", logging.RotateSize, logging.RotateDaily, r)
+	}
+	for _, f := range []struct {
+		field string
+		value int
+	}{
+		{"log_file.max_size_mb", c.LogFile.MaxSizeMB},
+		{"log_file.max_backups", c.LogFile.MaxBackups},
+		{"log_file.max_age_days", c.LogFile.MaxAgeDays},
+	} {
+		if f.value < 0 {
+			add(f.field, "must not be negative, got %d", f.value)
+		}
+	}
+
+	if c.Endpoint != "" && !isHTTPURL(c.Endpoint) {
+		add("endpoint", "must be an http or https URL such as https://api.gyazo.com, got %q", c.Endpoint)
+	}
+
+	if c.WorkerCount < 0 || c.WorkerCount > maxWorkerCount {
+		add("worker_count", "must be between 1 and %d, got %d", maxWorkerCount, c.WorkerCount)
+	}
+
+	if c.PauseAutoResume != "" {
+		if d, err := time.ParseDuration(c.PauseAutoResume); err != nil || d <= 0 {
+			add("pause_auto_resume", "must be a positive duration such as 30m or 1h, got %q", c.PauseAutoResume)
+		}
+	}
+
+	if c.OAuth != nil {
+		if c.OAuth.ClientID == "" {
+			add("oauth.client_id", "is required; register an application at https://gyazo.com/oauth/applications")
+		}
+		urls := []struct{ field, value string }{
+			{"oauth.authorize_url", c.OAuth.AuthorizeURL},
+			{"oauth.token_url", c.OAuth.TokenURL},
+			{"oauth.revoke_url", c.OAuth.RevokeURL},
+		}
+		for _, u := range urls {
+			if u.value != "" && !isHTTPURL(u.value) {
+				add(u.field, "must be an http or https URL, got %q", u.value)
+			}
+		}
+		if c.OAuth.RedirectPort < 0 || c.OAuth.RedirectPort > 65535 {
+			add("oauth.redirect_port", "must be between 1 and 65535, got %d", c.OAuth.RedirectPort)
+		}
+	}
+
+	if c.API != nil && (c.API.Port < 0 || c.API.Port > 65535) {
+		add("api.port", "must be between 1 and 65535, got %d", c.API.Port)
+	}
+	if c.Metrics != nil {
+		if c.Metrics.Port < 0 || c.Metrics.Port > 65535 {
+			add("metrics.port", "must be between 1 and 65535, got %d", c.Metrics.Port)
+		} else if c.API != nil && c.Metrics.ListenPort() == c.API.ListenPort() {
+			add("metrics.port", "must be different from api.port (%d)", c.API.ListenPort())
+		}
+	}
+
+	hotkeys := map[string]string{
+		"hotkeys.capture":        c.Hotkeys.CaptureKeys(),
+		"hotkeys.undo":           c.Hotkeys.UndoKeys(),
+		"hotkeys.switch_profile": c.Hotkeys.SwitchProfileKeys(),
+		"hotkeys.pause":          c.Hotkeys.PauseKeys(),
+	}
+	fields := []string{"hotkeys.capture", "hotkeys.undo"}
+	if len(c.Profiles) > 0 {
+		// プロファイルの切り替えはプロファイルがある場合だけ登録する
+		fields = append(fields, "hotkeys.switch_profile")
+	}
+	if c.Hotkeys.PauseKeys() != "" {
+		fields = append(fields, "hotkeys.pause")
+	}
+	seen := make(map[platform.KeyCombo]string)
+	for _, field := range fields {
+		combo, err := platform.ParseKeys(hotkeys[field])
+		if err != nil {
+			add(field, "%v", err)
+			continue
+		}
+		if other, ok := seen[combo]; ok {
+			add(field, "%s is already used by %s", hotkeys[field], other)
+			continue
+		}
+		seen[combo] = field
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Path: path, Problems: problems}
+}
+
+// isHTTPURL は s が http か https の URL かどうかを返す
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// isOutputFormat は s が output_format に指定できる値かどうかを返す
+func isOutputFormat(s string) bool {
+	switch s {
+	case "", OutputFormatURL, OutputFormatJSON, OutputFormatMarkdown:
+		return true
+	}
+	return false
+}
+
+// isAccessPolicy は s が access_policy に指定できる値かどうかを返す
+func isAccessPolicy(s string) bool {
+	switch s {
+	case "", gyazo.AccessPolicyAnyone, gyazo.AccessPolicyOnlyMe:
+		return true
+	}
+	return false
+}
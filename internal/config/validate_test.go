@@ -0,0 +1,130 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// writeConfig は data の {{dir}} を実在するディレクトリに置き換えて、t.TempDir() の name に書き込む
+func writeConfig(t *testing.T, name, data string) string {
+	t.Helper()
+	dir := t.TempDir()
+	data = strings.ReplaceAll(data, "{{dir}}", filepath.ToSlash(dir))
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// problemFields は Validate が報告した項目のキーを返す
+func problemFields(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var ve *ValidationError
+	if !errors.As(err, &ve) {
+		t.Fatalf("Validate: err = %v, want *ValidationError", err)
+	}
+	fields := make([]string, len(ve.Problems))
+	for i, p := range ve.Problems {
+		fields[i] = p.Field
+	}
+	return fields
+}
+
+func TestValidateFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   []string
+	}{
+		{
+			name:   "valid",
+			config: `{"version": 2, "gyazo_access_token": "token", "watches": [{"path": "{{dir}}"}]}`,
+		},
+		{
+			name:   "missing token and watches",
+			config: `{"version": 2}`,
+			want:   []string{"gyazo_access_token", "watches"},
+		},
+		{
+			name: "unknown keys",
+			config: `{"version": 2, "gyazo_access_token": "token", "watches": [{"path": "{{dir}}", "profle": "work"}],
+				"log_levl": "debug", "hotkeys": {"captuer": "Ctrl+Shift+X"}}`,
+			want: []string{"hotkeys.captuer", "log_levl", "watches[0].profle"},
+		},
+		{
+			name: "watches",
+			config: `{"version": 2, "gyazo_access_token": "token", "watches": [
+				{"path": "{{dir}}"}, {"path": ""}, {"path": "{{dir}}/"}, {"path": "{{dir}}/missing"},
+				{"path": "{{dir}}/config.json"}, {"path": "{{dir}}/..", "profile": "work"}]}`,
+			want: []string{"watches[1].path", "watches[2].path", "watches[3].path", "watches[4].path", "watches[5].profile"},
+		},
+		{
+			name: "values",
+			config: `{"version": 2, "gyazo_access_token": "token", "watches": [{"path": "{{dir}}"}],
+				"output_format": "html", "access_policy": "public", "log_level": "loud", "log_format": "xml",
+				"log_levels": {"uploader": "loud", "disk": "debug"},
+				"log_file": {"rotate": "weekly", "max_size_mb": -1, "max_age_days": -1},
+				"endpoint": "ftp://example.com", "worker_count": 100, "pause_auto_resume": "soon"}`,
+			want: []string{
+				"output_format", "access_policy", "log_level", "log_format", "log_levels.disk", "log_levels.uploader",
+				"log_file.rotate", "log_file.max_size_mb", "log_file.max_age_days", "endpoint", "worker_count", "pause_auto_resume",
+			},
+		},
+		{
+			name: "profiles",
+			config: `{"version": 2, "watches": [{"path": "{{dir}}"}],
+				"profiles": {"work": {"endpoint": "example.com"}, "home": {"gyazo_access_token": "token", "access_policy": "public", "output_format": "html"}}}`,
+			want: []string{
+				"gyazo_access_token",
+				"profiles.home.access_policy", "profiles.home.output_format",
+				"profiles.work.gyazo_access_token", "profiles.work.endpoint",
+			},
+		},
+		{
+			name: "oauth, api and metrics",
+			config: `{"version": 2, "gyazo_access_token": "token", "watches": [{"path": "{{dir}}"}],
+				"oauth": {"token_url": "token", "redirect_port": 70000}, "api": {"port": 18514}, "metrics": {}}`,
+			want: []string{"oauth.client_id", "oauth.token_url", "oauth.redirect_port", "metrics.port"},
+		},
+		{
+			name: "hotkeys",
+			config: `{"version": 2, "gyazo_access_token": "token", "watches": [{"path": "{{dir}}"}],
+				"hotkeys": {"capture": "Ctrl+Shift+U", "undo": "Ctrl+Shift+U", "pause": "Hyper+P"}}`,
+			want: []string{"hotkeys.undo", "hotkeys.pause"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfig(t, "config.json", tt.config)
+			cfg, err := LoadUnresolved(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = cfg.Validate(path)
+			if got := problemFields(t, err); !slices.Equal(got, tt.want) {
+				t.Errorf("problem fields = %q, want %q\n%v", got, tt.want, err)
+			}
+			if err != nil && !strings.HasPrefix(err.Error(), path+": ") {
+				t.Errorf("error does not start with the config path: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadInvalidVersion(t *testing.T) {
+	for _, version := range []string{`"2"`, `0`, `99`} {
+		path := writeConfig(t, "config.json", `{"version": `+version+`}`)
+		_, err := LoadUnresolved(path)
+		if got := problemFields(t, err); !slices.Equal(got, []string{"version"}) {
+			t.Errorf("version %s: problem fields = %q, want [version]", version, got)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+// Package deadletter はリトライしてもアップロードできなかった画像を保存する
+// 保存した画像は後から調べたり、アップロードし直したりできる
+package deadletter
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/zztkm/zgyazo/internal/jsonstore"
+	"github.com/zztkm/zgyazo/internal/platform"
+)
+
+// maxEntries は保存する件数の上限。古いものから削除する
+const maxEntries = 1000
+
+// Entry はアップロードできなかった画像 1 件
+type Entry struct {
+	FilePath string `json:"file_path"`
+
+	// Error は最後のアップロードのエラー
+	Error string `json:"error"`
+
+	// Attempts はアップロードを試みた回数
+	Attempts int `json:"attempts"`
+
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// Store はアップロードできなかった画像を JSON ファイルに保存する
+// 複数の goroutine から同時に使ってよい
+type Store struct {
+	store *jsonstore.Store[Entry]
+}
+
+// DefaultPath はファイルのパスを返す
+func DefaultPath() string {
+	return filepath.Join(platform.StateDir(), "deadletter.json")
+}
+
+// Open は path のファイルを読み込む
+// ファイルが存在しない場合は空として扱い、最初の書き込みで作成する
+func Open(path string) (*Store, error) {
+	store, err := jsonstore.Open[Entry](path, maxEntries)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{store: store}, nil
+}
+
+// Add は entry を追加して保存する
+// 同じファイルがすでにある場合は置き換える
+func (s *Store) Add(entry Entry) error {
+	return s.store.Update(func(entries []Entry) ([]Entry, bool) {
+		entries, _ = remove(entries, entry.FilePath)
+		return append(entries, entry), true
+	})
+}
+
+// Entries は古い順にすべての画像を返す
+func (s *Store) Entries() []Entry {
+	return s.store.Entries()
+}
+
+// Len は保存している件数を返す
+func (s *Store) Len() int {
+	return s.store.Len()
+}
+
+// Remove は filePath の画像を削除して保存する
+// アップロードし直して成功した場合に使う
+func (s *Store) Remove(filePath string) error {
+	return s.store.Update(func(entries []Entry) ([]Entry, bool) {
+		return remove(entries, filePath)
+	})
+}
+
+func remove(entries []Entry, filePath string) ([]Entry, bool) {
+	for i, e := range entries {
+		if e.FilePath == filePath {
+			return append(entries[:i], entries[i+1:]...), true
+		}
+	}
+	return entries, false
+}
@@ -0,0 +1,108 @@
+// Package history はアップロードした画像の履歴を保存する
+package history
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/zztkm/zgyazo/internal/jsonstore"
+	"github.com/zztkm/zgyazo/internal/platform"
+)
+
+// maxEntries は保存する履歴の最大件数。古いものから削除する
+const maxEntries = 1000
+
+// Entry はアップロードした画像 1 件分の履歴
+type Entry struct {
+	ImageID      string    `json:"image_id"`
+	PermalinkURL string    `json:"permalink_url"`
+	URL          string    `json:"url"`
+	FilePath     string    `json:"file_path"`
+	UploadedAt   time.Time `json:"uploaded_at"`
+
+	// Profile はアップロードしたプロファイルの名前 (プロファイルを使っていない場合は空)
+	// 削除するときは、このプロファイルのアカウントを使う
+	Profile string `json:"profile,omitempty"`
+
+	// DeletedAt は Gyazo から削除した日時 (削除していない場合は nil)
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// Deleted は画像が Gyazo から削除済みかどうかを返す
+func (e Entry) Deleted() bool {
+	return e.DeletedAt != nil
+}
+
+// Store は履歴を JSON ファイルに保存する
+// 複数の goroutine から同時に使ってよい
+type Store struct {
+	store *jsonstore.Store[Entry]
+}
+
+// DefaultPath は履歴ファイルのパスを返す
+func DefaultPath() string {
+	return filepath.Join(platform.StateDir(), "history.json")
+}
+
+// Open は path の履歴ファイルを読み込む
+// ファイルが存在しない場合は空の履歴として扱い、最初の書き込みで作成する
+func Open(path string) (*Store, error) {
+	store, err := jsonstore.Open[Entry](path, maxEntries)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{store: store}, nil
+}
+
+// Add は履歴を追加して保存する
+func (s *Store) Add(entry Entry) error {
+	return s.store.Update(func(entries []Entry) ([]Entry, bool) {
+		return append(entries, entry), true
+	})
+}
+
+// Entries は古い順にすべての履歴を返す
+func (s *Store) Entries() []Entry {
+	return s.store.Entries()
+}
+
+// Last は削除されていない最新の履歴を返す
+func (s *Store) Last() (entry Entry, found bool) {
+	s.store.View(func(entries []Entry) {
+		for i := len(entries) - 1; i >= 0; i-- {
+			if !entries[i].Deleted() {
+				entry, found = entries[i], true
+				return
+			}
+		}
+	})
+	return entry, found
+}
+
+// Find は imageID の履歴を返す
+func (s *Store) Find(imageID string) (entry Entry, found bool) {
+	s.store.View(func(entries []Entry) {
+		for i := len(entries) - 1; i >= 0; i-- {
+			if entries[i].ImageID == imageID {
+				entry, found = entries[i], true
+				return
+			}
+		}
+	})
+	return entry, found
+}
+
+// MarkDeleted は imageID の履歴を削除済みにして保存する
+// 履歴にない画像の場合は何もしない
+func (s *Store) MarkDeleted(imageID string, deletedAt time.Time) error {
+	return s.store.Update(func(entries []Entry) ([]Entry, bool) {
+		found := false
+		for i := range entries {
+			if entries[i].ImageID == imageID {
+				entries[i].DeletedAt = &deletedAt
+				found = true
+			}
+		}
+		return entries, found
+	})
+}
@@ -0,0 +1,56 @@
+package instance
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/zztkm/zgyazo/internal/platform"
+)
+
+// Address は常駐プロセスが要求を待ち受ける Unix ドメインソケットのパスを返す
+// ソケットの権限は環境によって無視されるため、自分だけが入れるディレクトリに置く
+func Address() string {
+	return filepath.Join(platform.StateDir(), "run", "zgyazo.sock")
+}
+
+// unixListener は Unix ドメインソケットで待ち受ける
+type unixListener struct {
+	net.Listener
+}
+
+func (l unixListener) Accept() (io.ReadWriteCloser, error) {
+	return l.Listener.Accept()
+}
+
+// listen は address のソケットで待ち受ける
+// ほかのユーザーから接続させないよう、ソケットを作成する前にディレクトリの権限を自分だけにする
+// ロックを取得したプロセスだけが呼び出すので、残っているソケットのファイルは削除してから開く
+func listen(address string) (listener, error) {
+	dir := filepath.Dir(address)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	// すでにあるディレクトリは MkdirAll で権限が変わらない
+	if err := os.Chmod(dir, 0700); err != nil {
+		return nil, err
+	}
+	if err := os.Remove(address); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	ln, err := net.Listen("unix", address)
+	if err != nil {
+		return nil, err
+	}
+	// Close でソケットのファイルも削除される
+	return unixListener{ln}, nil
+}
+
+// dial は address のソケットに接続する
+func dial(ctx context.Context, address string) (io.ReadWriteCloser, error) {
+	var d net.Dialer
+	return d.DialContext(ctx, "unix", address)
+}
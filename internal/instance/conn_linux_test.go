@@ -0,0 +1,35 @@
+package instance
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListenRestrictsSocketDirectory(t *testing.T) {
+	// 以前の実行で緩い権限のディレクトリと、残ったソケットのファイルがある
+	dir := filepath.Join(t.TempDir(), "run")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	address := filepath.Join(dir, "zgyazo.sock")
+	if err := os.WriteFile(address, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := listen(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0700 {
+		t.Errorf("socket directory permission = %o, want 700", perm)
+	}
+	if info, err := os.Stat(address); err != nil || info.Mode()&os.ModeSocket == 0 {
+		t.Errorf("%s is not a socket: %v", address, err)
+	}
+}
@@ -0,0 +1,168 @@
+package instance
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// pipeBufferSize は名前付きパイプのバッファのサイズ
+const pipeBufferSize = 64 * 1024
+
+// Address は常駐プロセスが要求を待ち受ける名前付きパイプの名前を返す
+// 同じ PC のほかのユーザーの zgyazo と区別するため、ユーザーの SID を含める
+func Address() string {
+	name := `\\.\pipe\zgyazo`
+	if user, err := windows.GetCurrentProcessToken().GetTokenUser(); err == nil {
+		name += "-" + user.User.Sid.String()
+	}
+	return name
+}
+
+// pipeListener は名前付きパイプで待ち受ける
+// 接続ごとにパイプのインスタンスを作成し、接続されるまでブロックする
+type pipeListener struct {
+	name string
+	sa   *windows.SecurityAttributes
+
+	mu     sync.Mutex
+	next   windows.Handle
+	closed bool
+}
+
+// listen は address の名前付きパイプで待ち受ける
+// 接続できるのは自分のユーザーだけで、ほかの PC からの接続は拒否する
+func listen(address string) (listener, error) {
+	user, err := windows.GetCurrentProcessToken().GetTokenUser()
+	if err != nil {
+		return nil, err
+	}
+	sd, err := windows.SecurityDescriptorFromString("D:P(A;;GA;;;" + user.User.Sid.String() + ")")
+	if err != nil {
+		return nil, err
+	}
+	l := &pipeListener{
+		name: address,
+		sa: &windows.SecurityAttributes{
+			Length:             uint32(unsafe.Sizeof(windows.SecurityAttributes{})),
+			SecurityDescriptor: sd,
+		},
+	}
+	// 最初のインスタンスは、同じ名前のパイプをほかのプロセスが作成していないことの確認を兼ねる
+	if l.next, err = l.create(true); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *pipeListener) create(first bool) (windows.Handle, error) {
+	flags := uint32(windows.PIPE_ACCESS_DUPLEX)
+	if first {
+		flags |= windows.FILE_FLAG_FIRST_PIPE_INSTANCE
+	}
+	name, err := windows.UTF16PtrFromString(l.name)
+	if err != nil {
+		return 0, err
+	}
+	return windows.CreateNamedPipe(name, flags,
+		windows.PIPE_TYPE_BYTE|windows.PIPE_READMODE_BYTE|windows.PIPE_WAIT|windows.PIPE_REJECT_REMOTE_CLIENTS,
+		windows.PIPE_UNLIMITED_INSTANCES, pipeBufferSize, pipeBufferSize, 0, l.sa)
+}
+
+func (l *pipeListener) Accept() (io.ReadWriteCloser, error) {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil, net.ErrClosed
+	}
+	h := l.next
+	l.next = 0
+	l.mu.Unlock()
+
+	if h == 0 {
+		var err error
+		if h, err = l.create(false); err != nil {
+			return nil, err
+		}
+	}
+	if err := windows.ConnectNamedPipe(h, nil); err != nil && !errors.Is(err, windows.ERROR_PIPE_CONNECTED) {
+		windows.CloseHandle(h)
+		return nil, err
+	}
+
+	l.mu.Lock()
+	closed := l.closed
+	l.mu.Unlock()
+	if closed {
+		// Close が Accept を終わらせるために接続した
+		windows.CloseHandle(h)
+		return nil, net.ErrClosed
+	}
+	return &pipeConn{File: os.NewFile(uintptr(h), l.name), handle: h}, nil
+}
+
+func (l *pipeListener) Close() error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	next := l.next
+	l.next = 0
+	l.mu.Unlock()
+
+	// ConnectNamedPipe で待っている Accept を終わらせるため、自分で接続する
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if conn, err := dial(ctx, l.name); err == nil {
+		conn.Close()
+	}
+	if next != 0 {
+		return windows.CloseHandle(next)
+	}
+	return nil
+}
+
+// pipeConn はサーバー側の名前付きパイプの接続
+type pipeConn struct {
+	*os.File
+	handle windows.Handle
+}
+
+// Close はクライアントがすべて読み終えてから切断する
+func (c *pipeConn) Close() error {
+	windows.FlushFileBuffers(c.handle)
+	windows.DisconnectNamedPipe(c.handle)
+	return c.File.Close()
+}
+
+// dial は address の名前付きパイプに接続する
+// すべてのインスタンスが使用中の場合は、空くか ctx が終了するまで待つ
+func dial(ctx context.Context, address string) (io.ReadWriteCloser, error) {
+	name, err := windows.UTF16PtrFromString(address)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		h, err := windows.CreateFile(name, windows.GENERIC_READ|windows.GENERIC_WRITE, 0, nil, windows.OPEN_EXISTING, 0, 0)
+		if err == nil {
+			return os.NewFile(uintptr(h), address), nil
+		}
+		if !errors.Is(err, windows.ERROR_PIPE_BUSY) {
+			return nil, &os.PathError{Op: "open", Path: address, Err: err}
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
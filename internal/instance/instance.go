@@ -0,0 +1,107 @@
+// Package instance は常駐プロセスが 1 つだけ起動するようにし、別のプロセスからの要求を常駐プロセスに転送する
+//
+// 常駐プロセスは状態ディレクトリの zgyazo.pid に PID を書き込んでロックとし、
+// Linux では Unix ドメインソケット、Windows では名前付きパイプで待ち受けて
+// zgyazo upload や zgyazo ctl などの要求を受け付ける
+package instance
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/zztkm/zgyazo/internal/platform"
+)
+
+// PIDPath は常駐プロセスの PID を書き込むファイルのパスを返す
+func PIDPath() string {
+	return filepath.Join(platform.StateDir(), "zgyazo.pid")
+}
+
+// RunningError は別の常駐プロセスが起動していることを表す
+type RunningError struct {
+	PID int
+}
+
+func (e *RunningError) Error() string {
+	return fmt.Sprintf("zgyazo is already running (pid %d)", e.PID)
+}
+
+// Lock は常駐プロセスが起動していることを表すロック
+type Lock struct {
+	path string
+	pid  int
+}
+
+// Acquire は path に自分の PID を書き込んでロックを取得する
+// 別の常駐プロセスが起動している場合は *RunningError を返す
+// 異常終了などで残ったファイル (PID のプロセスが zgyazo ではない場合も含む) は削除して取得し直す
+func Acquire(path string) (*Lock, error) {
+	pid := os.Getpid()
+	for range 2 {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			_, err = fmt.Fprintf(f, "%d\n", pid)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(path)
+				return nil, err
+			}
+			return &Lock{path: path, pid: pid}, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+
+		if running, ok := Running(path); ok {
+			return nil, &RunningError{PID: running}
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+	// 削除した直後に別のプロセスが作成した
+	running, _ := readPID(path)
+	return nil, &RunningError{PID: running}
+}
+
+// Release はロックを解放する
+// ファイルがほかのプロセスに書き換えられている場合は削除しない
+func (l *Lock) Release() error {
+	if pid, err := readPID(l.path); err != nil || pid != l.pid {
+		return nil
+	}
+	return os.Remove(l.path)
+}
+
+// Running は path に書き込まれた PID の常駐プロセスが起動しているかどうかと、その PID を返す
+func Running(path string) (int, bool) {
+	pid, err := readPID(path)
+	if err != nil || pid == os.Getpid() {
+		return 0, false
+	}
+	return pid, processRunning(pid)
+}
+
+func readPID(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+// sameExecutable は name が自分と同じ実行ファイルの名前かどうかを返す
+// PID が再利用されて別のプロセスになっている場合を、残ったファイルとして扱うために使う
+func sameExecutable(name string) bool {
+	self, err := os.Executable()
+	if err != nil {
+		return true
+	}
+	return strings.EqualFold(filepath.Base(name), filepath.Base(self))
+}
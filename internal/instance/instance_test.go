@@ -0,0 +1,149 @@
+package instance
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// envHelperProcess を設定すると、テストのバイナリを終了されるまで待つだけのプロセスとして起動する
+// 実行中の zgyazo (同じ実行ファイルのプロセス) の代わりに使う
+const envHelperProcess = "ZGYAZO_INSTANCE_HELPER_PROCESS"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(envHelperProcess) != "" {
+		time.Sleep(time.Hour)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// startHelperProcess は同じ実行ファイルのプロセスを起動し、その PID を返す
+func startHelperProcess(t *testing.T) int {
+	t.Helper()
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(exe)
+	cmd.Env = append(os.Environ(), envHelperProcess+"=1")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	return cmd.Process.Pid
+}
+
+// exitedPID は終了したプロセスの PID を返す
+func exitedPID(t *testing.T) int {
+	t.Helper()
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(exe, "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	return cmd.Process.Pid
+}
+
+func writePID(t *testing.T, path string, pid int) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(fmt.Sprintf("%d\n", pid)), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAcquireAndRelease(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zgyazo.pid")
+	lock, err := Acquire(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pid, err := readPID(path); err != nil || pid != os.Getpid() {
+		t.Errorf("PID file = %d, %v, want %d", pid, err, os.Getpid())
+	}
+	// 自分の PID は起動中の別の常駐プロセスとして扱わない
+	if _, running := Running(path); running {
+		t.Error("Running reported this process as another daemon")
+	}
+	if err := lock.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("PID file was not removed: %v", err)
+	}
+}
+
+func TestAcquireStalePIDFile(t *testing.T) {
+	tests := []struct {
+		name string
+		data func(t *testing.T) string
+	}{
+		{name: "exited process", data: func(t *testing.T) string { return fmt.Sprintf("%d\n", exitedPID(t)) }},
+		// PID が再利用され、zgyazo ではないプロセスになっている
+		{name: "other executable", data: func(t *testing.T) string { return fmt.Sprintf("%d\n", os.Getppid()) }},
+		{name: "broken", data: func(t *testing.T) string { return "not a pid" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "zgyazo.pid")
+			if err := os.WriteFile(path, []byte(tt.data(t)), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if pid, running := Running(path); running {
+				t.Errorf("Running = %d, true for a stale PID file", pid)
+			}
+			lock, err := Acquire(path)
+			if err != nil {
+				t.Fatalf("Acquire with a stale PID file: %v", err)
+			}
+			defer lock.Release()
+			if pid, err := readPID(path); err != nil || pid != os.Getpid() {
+				t.Errorf("PID file = %d, %v, want %d", pid, err, os.Getpid())
+			}
+		})
+	}
+}
+
+func TestAcquireWhileRunning(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zgyazo.pid")
+	pid := startHelperProcess(t)
+	writePID(t, path, pid)
+
+	if got, running := Running(path); !running || got != pid {
+		t.Errorf("Running = %d, %v, want %d, true", got, running, pid)
+	}
+	_, err := Acquire(path)
+	var runningErr *RunningError
+	if !errors.As(err, &runningErr) || runningErr.PID != pid {
+		t.Fatalf("Acquire: err = %v, want *RunningError for pid %d", err, pid)
+	}
+	if got, err := readPID(path); err != nil || got != pid {
+		t.Errorf("PID file of the running process was changed to %d, %v", got, err)
+	}
+}
+
+func TestReleaseKeepsOtherProcessLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zgyazo.pid")
+	lock, err := Acquire(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 異常終了したと判断した別のプロセスがロックを取り直した
+	writePID(t, path, os.Getpid()+1)
+	if err := lock.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Release removed the PID file of another process: %v", err)
+	}
+}
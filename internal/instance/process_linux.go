@@ -0,0 +1,24 @@
+package instance
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// processRunning は pid のプロセスが zgyazo として実行中かどうかを返す
+func processRunning(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	if err := syscall.Kill(pid, 0); err != nil && !errors.Is(err, syscall.EPERM) {
+		return false
+	}
+	exe, err := os.Readlink("/proc/" + strconv.Itoa(pid) + "/exe")
+	if err != nil {
+		// 別のユーザーのプロセスなどで確認できない場合は、実行中として扱う
+		return true
+	}
+	return sameExecutable(exe)
+}
@@ -0,0 +1,32 @@
+package instance
+
+import (
+	"golang.org/x/sys/windows"
+)
+
+// stillActive は GetExitCodeProcess で実行中のプロセスが返す値 (STILL_ACTIVE)
+const stillActive = 259
+
+// processRunning は pid のプロセスが zgyazo として実行中かどうかを返す
+func processRunning(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		// アクセスが拒否された場合はプロセスが存在する
+		return err == windows.ERROR_ACCESS_DENIED
+	}
+	defer windows.CloseHandle(h)
+
+	var code uint32
+	if err := windows.GetExitCodeProcess(h, &code); err != nil || code != stillActive {
+		return false
+	}
+	buf := make([]uint16, windows.MAX_LONG_PATH)
+	size := uint32(len(buf))
+	if err := windows.QueryFullProcessImageName(h, 0, &buf[0], &size); err != nil {
+		return true
+	}
+	return sameExecutable(windows.UTF16ToString(buf[:size]))
+}
@@ -0,0 +1,223 @@
+package instance
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/zztkm/zgyazo/internal/logging"
+)
+
+var logger = logging.Logger(logging.ComponentApp)
+
+// maxMessageSize は 1 つのメッセージの最大サイズ
+const maxMessageSize = 1 << 20
+
+// Request は常駐プロセスに送る要求
+//
+// メッセージは 4 バイトのビッグエンディアンの長さと、その長さの JSON からなる
+// 1 つの接続で複数の要求を順に送ることができ、要求ごとに Response が 1 つ返る
+type Request struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// Response は常駐プロセスからの応答
+type Response struct {
+	Result json.RawMessage `json:"result,omitempty"`
+
+	// Error は要求を処理できなかった理由 (成功した場合は空)
+	Error string `json:"error,omitempty"`
+}
+
+// HandlerFunc は params を受け取って要求を処理し、結果を返す
+type HandlerFunc func(ctx context.Context, params json.RawMessage) (any, error)
+
+// listener は Unix ドメインソケットや名前付きパイプで接続を待ち受ける
+type listener interface {
+	Accept() (io.ReadWriteCloser, error)
+	Close() error
+}
+
+// Server は常駐プロセスで要求を待ち受ける
+type Server struct {
+	address  string
+	handlers map[string]HandlerFunc
+
+	mu sync.Mutex
+	ln listener
+}
+
+// NewServer は address (Address が返すソケットやパイプ) で待ち受ける Server を生成する
+func NewServer(address string) *Server {
+	return &Server{address: address, handlers: make(map[string]HandlerFunc)}
+}
+
+// Handle は method の要求を処理する関数を登録する
+// Start の前に呼び出す
+func (s *Server) Handle(method string, h HandlerFunc) {
+	s.handlers[method] = h
+}
+
+// Start は待ち受けを始めて、別の goroutine で要求の処理を始める
+// ロックを取得したプロセスだけが呼び出す
+func (s *Server) Start() error {
+	ln, err := listen(s.address)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.ln = ln
+	s.mu.Unlock()
+	logger.Debug("listening for control requests", "address", s.address)
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					logger.Error("failed to accept control connection", "error", err)
+				}
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return nil
+}
+
+// Close は待ち受けを止める
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ln == nil {
+		return nil
+	}
+	err := s.ln.Close()
+	s.ln = nil
+	return err
+}
+
+// serve は接続が閉じられるまで要求を処理する
+func (s *Server) serve(conn io.ReadWriteCloser) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		var req Request
+		if err := readMessage(r, &req); err != nil {
+			if !errors.Is(err, io.EOF) {
+				logger.Warn("invalid control request", "error", err)
+			}
+			return
+		}
+		logger.Debug("received control request", "method", req.Method)
+
+		var res Response
+		if h, ok := s.handlers[req.Method]; !ok {
+			res.Error = fmt.Sprintf("unknown method: %s", req.Method)
+		} else if out, err := h(context.Background(), req.Params); err != nil {
+			res.Error = err.Error()
+		} else if res.Result, err = json.Marshal(out); err != nil {
+			res.Error = err.Error()
+		}
+		if err := writeMessage(conn, res); err != nil {
+			logger.Warn("failed to reply to control request", "method", req.Method, "error", err)
+			return
+		}
+	}
+}
+
+// ErrNotRunning は常駐プロセスが起動していないことを表す
+var ErrNotRunning = errors.New("zgyazo is not running")
+
+// Call は常駐プロセスに method の要求を送り、結果を out に読み込む
+// 常駐プロセスに接続できない場合は ErrNotRunning を返す
+func Call(ctx context.Context, method string, params, out any) error {
+	conn, err := dial(ctx, Address())
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrNotRunning, err)
+	}
+	defer conn.Close()
+	// 名前付きパイプはタイムアウトを設定できないため、ctx が終了したら閉じて読み書きを終わらせる
+	stop := context.AfterFunc(ctx, func() {
+		conn.Close()
+	})
+	defer stop()
+
+	req := Request{Method: method}
+	if params != nil {
+		if req.Params, err = json.Marshal(params); err != nil {
+			return err
+		}
+	}
+	if err := writeMessage(conn, req); err != nil {
+		return err
+	}
+	var res Response
+	if err := readMessage(bufio.NewReader(conn), &res); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	if res.Error != "" {
+		return &MethodError{Method: method, Message: res.Error}
+	}
+	if out == nil || len(res.Result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(res.Result, out)
+}
+
+// MethodError は常駐プロセスが要求を処理できなかったことを表す
+type MethodError struct {
+	Method  string
+	Message string
+}
+
+func (e *MethodError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Method, e.Message)
+}
+
+// writeMessage は v を長さ付きの JSON として w に書き込む
+func writeMessage(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if len(data) > maxMessageSize {
+		return fmt.Errorf("message is too large (%d bytes)", len(data))
+	}
+	buf := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint32(buf, uint32(len(data)))
+	copy(buf[4:], data)
+	_, err = w.Write(buf)
+	return err
+}
+
+// readMessage は長さ付きの JSON を r から読み込んで v に格納する
+// 接続が閉じられていた場合は io.EOF を返す
+func readMessage(r io.Reader, v any) error {
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return err
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n > maxMessageSize {
+		return fmt.Errorf("message is too large (%d bytes)", n)
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		if errors.Is(err, io.EOF) {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
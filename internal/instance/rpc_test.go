@@ -0,0 +1,183 @@
+package instance
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// callTimeout は常駐プロセスとのやり取りを待つ時間
+const callTimeout = 5 * time.Second
+
+// startServer は t.TempDir() の状態ディレクトリで echo を処理する Server を起動する
+func startServer(t *testing.T) *Server {
+	t.Helper()
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	server := NewServer(Address())
+	server.Handle("echo", func(ctx context.Context, params json.RawMessage) (any, error) {
+		var s string
+		if err := json.Unmarshal(params, &s); err != nil {
+			return nil, err
+		}
+		if s == "" {
+			return nil, errors.New("empty message")
+		}
+		return s, nil
+	})
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+	return server
+}
+
+// frame は長さ size のヘッダーと data からなるメッセージを返す
+func frame(size uint32, data string) []byte {
+	buf := binary.BigEndian.AppendUint32(nil, size)
+	return append(buf, data...)
+}
+
+func TestMessageRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	want := Request{Method: "status", Params: json.RawMessage(`{"limit":5}`)}
+	if err := writeMessage(&buf, want); err != nil {
+		t.Fatal(err)
+	}
+	if size := binary.BigEndian.Uint32(buf.Bytes()); int(size) != buf.Len()-4 {
+		t.Errorf("length prefix = %d, want %d", size, buf.Len()-4)
+	}
+	var got Request
+	if err := readMessage(&buf, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Method != want.Method || string(got.Params) != string(want.Params) {
+		t.Errorf("read %+v, want %+v", got, want)
+	}
+}
+
+func TestReadMessageInvalidFrames(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{name: "closed", data: nil, want: io.EOF},
+		{name: "short length", data: []byte{0, 0}, want: io.ErrUnexpectedEOF},
+		{name: "short body", data: frame(10, `{"a"`), want: io.ErrUnexpectedEOF},
+		{name: "missing body", data: frame(10, ""), want: io.ErrUnexpectedEOF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req Request
+			if err := readMessage(bytes.NewReader(tt.data), &req); !errors.Is(err, tt.want) {
+				t.Errorf("readMessage: err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadMessageTooLarge(t *testing.T) {
+	// ヘッダーだけで判断し、本文を読み込まない
+	var req Request
+	err := readMessage(bytes.NewReader(frame(maxMessageSize+1, "")), &req)
+	if err == nil || !strings.Contains(err.Error(), "too large") {
+		t.Errorf("readMessage: err = %v, want a too large error", err)
+	}
+}
+
+func TestWriteMessageTooLarge(t *testing.T) {
+	var buf bytes.Buffer
+	err := writeMessage(&buf, strings.Repeat("a", maxMessageSize))
+	if err == nil || !strings.Contains(err.Error(), "too large") {
+		t.Errorf("writeMessage: err = %v, want a too large error", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("wrote %d bytes of a message that is too large", buf.Len())
+	}
+}
+
+func TestCall(t *testing.T) {
+	startServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+
+	var out string
+	if err := Call(ctx, "echo", "hello", &out); err != nil {
+		t.Fatal(err)
+	}
+	if out != "hello" {
+		t.Errorf("result = %q, want %q", out, "hello")
+	}
+
+	var methodErr *MethodError
+	if err := Call(ctx, "echo", "", &out); !errors.As(err, &methodErr) || methodErr.Message != "empty message" {
+		t.Errorf("Call with a failing handler: err = %v, want the handler's error", err)
+	}
+}
+
+func TestCallUnknownMethod(t *testing.T) {
+	startServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+
+	err := Call(ctx, "missing", nil, nil)
+	var methodErr *MethodError
+	if !errors.As(err, &methodErr) {
+		t.Fatalf("Call: err = %v, want *MethodError", err)
+	}
+	if methodErr.Method != "missing" || !strings.Contains(methodErr.Message, "unknown method") {
+		t.Errorf("Call: err = %+v, want an unknown method error", methodErr)
+	}
+}
+
+func TestCallNotRunning(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+	if err := Call(ctx, "echo", "hello", nil); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("Call without a server: err = %v, want ErrNotRunning", err)
+	}
+}
+
+func TestServerClosesConnectionOnInvalidFrame(t *testing.T) {
+	startServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+
+	for name, data := range map[string][]byte{
+		"too large":    frame(maxMessageSize+1, ""),
+		"invalid json": frame(1, "{"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			conn, err := dial(ctx, Address())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			stop := context.AfterFunc(ctx, func() { conn.Close() })
+			defer stop()
+
+			if _, err := conn.Write(data); err != nil {
+				t.Fatal(err)
+			}
+			// 応答を返さずに接続を閉じる
+			var res Response
+			if err := readMessage(bufio.NewReader(conn), &res); !errors.Is(err, io.EOF) {
+				t.Errorf("read after an invalid frame: err = %v, res = %+v, want the connection to be closed", err, res)
+			}
+		})
+	}
+
+	// ほかの接続の要求は続けて処理する
+	var out string
+	if err := Call(ctx, "echo", "still running", &out); err != nil || out != "still running" {
+		t.Errorf("Call after invalid frames = %q, %v", out, err)
+	}
+}
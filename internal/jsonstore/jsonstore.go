@@ -0,0 +1,112 @@
+// Package jsonstore は件数の限られた記録を 1 つの JSON ファイルに保存する
+// 履歴やアップロード待ちの画像など、常駐プロセスの状態を保存するパッケージで共通に使う
+package jsonstore
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"slices"
+	"sync"
+)
+
+// Store は T の一覧を JSON ファイルに保存する
+// 複数の goroutine から同時に使ってよい
+type Store[T any] struct {
+	mu         sync.Mutex
+	path       string
+	maxEntries int
+	entries    []T
+}
+
+// Open は path のファイルを読み込む
+// ファイルが存在しない場合は空として扱い、最初の書き込みで作成する
+// maxEntries が 0 より大きい場合、保存する件数がそれを超えると古いものから削除する
+func Open[T any](path string, maxEntries int) (*Store[T], error) {
+	s := &Store[T]{path: path, maxEntries: maxEntries}
+	entries, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	s.entries = entries
+	return s, nil
+}
+
+// load はファイルから記録を読み込む
+// ファイルが存在しない場合は空として扱う
+func (s *Store[T]) load() ([]T, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []T
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Entries は古い順にすべての記録を返す
+func (s *Store[T]) Entries() []T {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]T(nil), s.entries...)
+}
+
+// Len は保存している件数を返す
+func (s *Store[T]) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+// View はロックを取ったまま fn に記録を渡す
+// fn は渡された記録を変更したり、呼び出しのあとまで保持したりしてはいけない
+func (s *Store[T]) View(fn func(entries []T)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(s.entries)
+}
+
+// Update はロックを取ったまま fn で記録を変更し、変更があれば保存する
+// fn は変更後の記録と、変更したかどうかを返す
+// fn には記録のコピーを渡し、保存に成功した場合だけ変更を反映する
+// 別のプロセスが同じファイルを書き換えていても上書きしないよう、ファイルから読み込み直してから fn を呼び出す
+func (s *Store[T]) Update(fn func(entries []T) ([]T, bool)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, err := s.load()
+	if err != nil {
+		return err
+	}
+	s.entries = current
+	entries, changed := fn(slices.Clone(s.entries))
+	if !changed {
+		return nil
+	}
+	if s.maxEntries > 0 && len(entries) > s.maxEntries {
+		entries = entries[len(entries)-s.maxEntries:]
+	}
+	if err := s.save(entries); err != nil {
+		return err
+	}
+	s.entries = entries
+	return nil
+}
+
+// save は entries を一時ファイルに書き込んでから置き換える
+// 書き込み中に終了してもファイルが壊れないようにするため
+func (s *Store[T]) save(entries []T) error {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,136 @@
+package jsonstore
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func appendValue(v int) func([]int) ([]int, bool) {
+	return func(entries []int) ([]int, bool) { return append(entries, v), true }
+}
+
+func TestUpdateSavesAndReopens(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	s, err := Open[int](path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Len() != 0 {
+		t.Fatalf("Len of a missing file = %d, want 0", s.Len())
+	}
+	for i := range 3 {
+		if err := s.Update(appendValue(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(path + ".tmp"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("temporary file was left behind: %v", err)
+	}
+
+	reopened, err := Open[int](path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reopened.Entries(); !slices.Equal(got, []int{0, 1, 2}) {
+		t.Errorf("reopened entries = %v, want [0 1 2]", got)
+	}
+}
+
+func TestUpdateTrimsOldestEntries(t *testing.T) {
+	s, err := Open[int](filepath.Join(t.TempDir(), "store.json"), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 3 {
+		if err := s.Update(appendValue(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := s.Entries(); !slices.Equal(got, []int{1, 2}) {
+		t.Errorf("entries = %v, want [1 2]", got)
+	}
+}
+
+func TestUpdateWithoutChangeDoesNotSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	s, err := Open[int](path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Update(func(entries []int) ([]int, bool) { return entries, false })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("file was written without a change: %v", err)
+	}
+}
+
+func TestUpdateKeepsChangesFromOtherStores(t *testing.T) {
+	// 常駐プロセスとコマンドラインのように、別々に開いた Store が同じファイルを更新する
+	path := filepath.Join(t.TempDir(), "store.json")
+	daemon, err := Open[int](path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := daemon.Update(appendValue(0)); err != nil {
+		t.Fatal(err)
+	}
+	cli, err := Open[int](path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.Update(appendValue(1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := daemon.Update(appendValue(2)); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := Open[int](path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reopened.Entries(); !slices.Equal(got, []int{0, 1, 2}) {
+		t.Errorf("entries = %v, want [0 1 2]", got)
+	}
+}
+
+func TestUpdateFailureKeepsEntries(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open[int](filepath.Join(dir, "store.json"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Update(appendValue(0)); err != nil {
+		t.Fatal(err)
+	}
+
+	// 一時ファイルの場所をディレクトリにして、保存に失敗させる
+	if err := os.Mkdir(filepath.Join(dir, "store.json.tmp"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	err = s.Update(func(entries []int) ([]int, bool) {
+		entries[0] = 100
+		return append(entries, 1), true
+	})
+	if err == nil {
+		t.Fatal("Update should fail when the file cannot be written")
+	}
+	if got := s.Entries(); !slices.Equal(got, []int{0}) {
+		t.Errorf("entries after a failed save = %v, want [0]", got)
+	}
+}
+
+func TestOpenInvalidJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	if err := os.WriteFile(path, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open[int](path, 0); err == nil {
+		t.Error("Open should fail for a broken file")
+	}
+}
@@ -0,0 +1,41 @@
+package logging
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// ParseLevel は "debug", "info", "warn", "error" を slog.Level に変換する
+// 空文字列の場合は slog.LevelInfo を返す (debug は明示したときだけ出力する)
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return slog.LevelInfo, fmt.Errorf("unknown log level %q (debug, info, warn, error)", s)
+}
+
+// ログの形式
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// ParseFormat はログの形式を検証する
+// 空文字列の場合は FormatText を返す
+func ParseFormat(s string) (string, error) {
+	switch strings.ToLower(s) {
+	case "", FormatText:
+		return FormatText, nil
+	case FormatJSON:
+		return FormatJSON, nil
+	}
+	return "", fmt.Errorf("unknown log format %q (text, json)", s)
+}
@@ -0,0 +1,180 @@
+// Package logging はログの出力先、形式、レベルを扱う
+//
+// ログは log/slog で出力する。パッケージごとに Logger でコンポーネント名の付いたロガーを作り、
+// Options.ComponentLevels でコンポーネントごとにレベルを変更できる。
+// ロガーは出力のたびに Configure で設定したハンドラーを使うため、パッケージの初期化時に作ってよい
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// コンポーネント名 (ログの component 属性と、log_levels のキー)
+const (
+	ComponentApp      = "app"
+	ComponentUploader = "uploader"
+	ComponentGyazo    = "gyazo"
+	ComponentConfig   = "config"
+	ComponentAuth     = "auth"
+	ComponentHotkey   = "hotkey"
+	ComponentPlatform = "platform"
+	ComponentAPI      = "api"
+	ComponentMetrics  = "metrics"
+)
+
+// Components はすべてのコンポーネント名
+var Components = []string{
+	ComponentApp,
+	ComponentUploader,
+	ComponentGyazo,
+	ComponentConfig,
+	ComponentAuth,
+	ComponentHotkey,
+	ComponentPlatform,
+	ComponentAPI,
+	ComponentMetrics,
+}
+
+// IsComponent は name がコンポーネント名かどうかを返す
+func IsComponent(name string) bool {
+	return slices.Contains(Components, name)
+}
+
+// Options はログの出力方法
+type Options struct {
+	// Level は出力する最低のレベル
+	Level slog.Level
+
+	// Format は FormatText か FormatJSON
+	Format string
+
+	// ComponentLevels はコンポーネントごとに Level を上書きする
+	ComponentLevels map[string]slog.Level
+
+	// Secrets はログに出力しないアクセストークンなどの値
+	// Authorization ヘッダーとトークンらしいパラメーターは指定しなくても伏せる
+	Secrets []string
+
+	// RedactHome はホームディレクトリのパスを "~" に置き換えるかどうか
+	RedactHome bool
+}
+
+// state は Configure で設定した出力先とハンドラー
+type state struct {
+	w       io.Writer
+	opts    Options
+	handler slog.Handler
+}
+
+// levelFor は component のログで出力する最低のレベルを返す
+func (s *state) levelFor(component string) slog.Level {
+	if level, ok := s.opts.ComponentLevels[component]; ok {
+		return level
+	}
+	return s.opts.Level
+}
+
+var current atomic.Pointer[state]
+
+func init() {
+	Configure(os.Stderr, Options{Level: slog.LevelInfo})
+}
+
+// Configure はログを opts に従って w に出力するように設定する
+// log パッケージのログも ComponentApp のログとして出力する
+func Configure(w io.Writer, opts Options) {
+	handlerOpts := &slog.HandlerOptions{
+		// レベルは componentHandler で判定する
+		Level:       slog.LevelDebug,
+		ReplaceAttr: NewRedactor(opts.Secrets, opts.RedactHome).replaceAttr,
+	}
+	var handler slog.Handler
+	if opts.Format == FormatJSON {
+		handler = slog.NewJSONHandler(w, handlerOpts)
+	} else {
+		handler = slog.NewTextHandler(w, handlerOpts)
+	}
+	current.Store(&state{w: w, opts: opts, handler: handler})
+	slog.SetDefault(Logger(ComponentApp))
+}
+
+// SetOptions は出力先を変えずに、ログの形式とレベルを変更する
+// 常駐プロセスで設定ファイルの変更を反映するときに使う
+func SetOptions(opts Options) {
+	Configure(current.Load().w, opts)
+}
+
+// Discard はログを出力しないように設定する
+func Discard() {
+	Configure(io.Discard, Options{Level: slog.LevelError + 1})
+}
+
+// Logger は component のログを出力するロガーを返す
+func Logger(component string) *slog.Logger {
+	return slog.New(&componentHandler{component: component})
+}
+
+// componentHandler は Configure で設定したハンドラーに、コンポーネント名を付けて出力する
+type componentHandler struct {
+	component string
+
+	// ops は WithAttrs と WithGroup の呼び出し
+	// 出力のたびに現在のハンドラーに適用する
+	ops []func(slog.Handler) slog.Handler
+}
+
+func (h *componentHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= current.Load().levelFor(h.component)
+}
+
+func (h *componentHandler) Handle(ctx context.Context, r slog.Record) error {
+	handler := current.Load().handler.WithAttrs([]slog.Attr{slog.String("component", h.component)})
+	for _, op := range h.ops {
+		handler = op(handler)
+	}
+	return handler.Handle(ctx, r)
+}
+
+func (h *componentHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(handler slog.Handler) slog.Handler { return handler.WithAttrs(attrs) })
+}
+
+func (h *componentHandler) WithGroup(name string) slog.Handler {
+	return h.with(func(handler slog.Handler) slog.Handler { return handler.WithGroup(name) })
+}
+
+func (h *componentHandler) with(op func(slog.Handler) slog.Handler) *componentHandler {
+	return &componentHandler{component: h.component, ops: append(slices.Clip(h.ops), op)}
+}
+
+// Setup は logPath に書き込む Rotator を作成し、標準出力とログファイルの両方にログを出力するように設定する
+// ローテーションは DefaultRotateOptions で行う。設定ファイルを読み込んだら Rotator.SetOptions で変更する
+func Setup(logPath string, opts Options) (*Rotator, error) {
+	rotator := NewRotator(logPath, DefaultRotateOptions())
+
+	// 初期ファイルを開く
+	if err := rotator.openFile(); err != nil {
+		return nil, err
+	}
+	Configure(io.MultiWriter(os.Stdout, rotator), opts)
+	return rotator, nil
+}
+
+// StartFlusher は interval ごとにログファイルをディスクに書き出す goroutine を開始する
+func StartFlusher(rotator *Rotator, interval time.Duration) {
+	logger := Logger(ComponentApp)
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			if err := rotator.Sync(); err != nil {
+				logger.Error("failed to sync log file", "error", err)
+			}
+		}
+	}()
+}
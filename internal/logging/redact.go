@@ -0,0 +1,101 @@
+package logging
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Redacted は伏せた値の代わりに出力する文字列
+const Redacted = "[REDACTED]"
+
+// minSecretLength より短い値は伏せない
+// 短い値を伏せると、関係のない単語まで書き換えてしまうため
+const minSecretLength = 8
+
+var (
+	// authorizationPattern は Authorization ヘッダーと Bearer トークン
+	authorizationPattern = regexp.MustCompile(`(?i)(authorization["']?\s*[:=]\s*["']?(?:bearer|basic|token)?\s*|bearer\s+)[^\s"',;&]+`)
+
+	// tokenParamPattern は URL のクエリや JSON に含まれるトークン
+	tokenParamPattern = regexp.MustCompile(`(?i)((?:access_token|refresh_token|client_secret|code_verifier)["']?\s*[:=]\s*["']?)[^\s"',;&]+`)
+)
+
+// sensitiveKeys は値をすべて伏せる属性のキー
+var sensitiveKeys = []string{"token", "access_token", "authorization", "client_secret", "password", "secret"}
+
+// Redactor はログに含まれるアクセストークンや Authorization ヘッダー、ホームディレクトリのパスを伏せる
+// バグ報告にログファイルを添付しても、トークンや個人的なパスが漏れないようにするため
+type Redactor struct {
+	secrets []string
+
+	// home は "~" に置き換えるホームディレクトリ (空の場合は置き換えない)
+	home string
+}
+
+// NewRedactor は secrets と、redactHome が true の場合はホームディレクトリのパスを伏せる Redactor を生成する
+func NewRedactor(secrets []string, redactHome bool) *Redactor {
+	r := &Redactor{}
+	for _, s := range secrets {
+		if len(s) >= minSecretLength {
+			r.secrets = append(r.secrets, s)
+		}
+	}
+	if redactHome {
+		if home, err := os.UserHomeDir(); err == nil && len(home) > 1 {
+			r.home = filepath.Clean(home)
+		}
+	}
+	return r
+}
+
+// String は s に含まれる秘密の値を伏せる
+func (r *Redactor) String(s string) string {
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, Redacted)
+	}
+	s = authorizationPattern.ReplaceAllString(s, "${1}"+Redacted)
+	s = tokenParamPattern.ReplaceAllString(s, "${1}"+Redacted)
+	if r.home != "" {
+		s = strings.ReplaceAll(s, r.home, "~")
+		if filepath.Separator == '\\' {
+			// JSON では \ がエスケープされるため、エスケープした形も置き換える
+			s = strings.ReplaceAll(s, strings.ReplaceAll(r.home, `\`, `\\`), "~")
+		}
+	}
+	return s
+}
+
+// replaceAttr は slog.HandlerOptions.ReplaceAttr として属性の値を伏せる
+func (r *Redactor) replaceAttr(_ []string, a slog.Attr) slog.Attr {
+	for _, key := range sensitiveKeys {
+		if strings.EqualFold(a.Key, key) {
+			return slog.String(a.Key, Redacted)
+		}
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(r.String(a.Value.String()))
+	case slog.KindAny:
+		switch v := a.Value.Any().(type) {
+		case error:
+			a.Value = slog.StringValue(r.String(v.Error()))
+		case []string:
+			redacted := make([]string, len(v))
+			for i, s := range v {
+				redacted[i] = r.String(s)
+			}
+			a.Value = slog.AnyValue(redacted)
+		default:
+			// 構造体などをそのまま渡された場合に備え、文字列にして伏せる値があれば置き換える
+			s := fmt.Sprintf("%+v", v)
+			if redacted := r.String(s); redacted != s {
+				a.Value = slog.StringValue(redacted)
+			}
+		}
+	}
+	return a
+}
@@ -0,0 +1,126 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// secretToken はテストで伏せるアクセストークン
+const secretToken = "s3cr3t-access-token"
+
+func TestRedactorString(t *testing.T) {
+	r := NewRedactor([]string{secretToken, "short"}, false)
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "header",
+			in:   "Authorization: Bearer abcdefghijkl",
+			want: "Authorization: Bearer " + Redacted,
+		},
+		{
+			name: "header in JSON",
+			in:   `{"Authorization":"Basic dXNlcjpwYXNz"}`,
+			want: `{"Authorization":"Basic ` + Redacted + `"}`,
+		},
+		{
+			name: "bearer",
+			in:   "request failed: bearer abcdefghijkl rejected",
+			want: "request failed: bearer " + Redacted + " rejected",
+		},
+		{
+			name: "query",
+			in:   "GET https://api.gyazo.com/api/images?access_token=abcdefghijkl&page=2",
+			want: "GET https://api.gyazo.com/api/images?access_token=" + Redacted + "&page=2",
+		},
+		{
+			name: "oauth form",
+			in:   "code_verifier=abc&refresh_token=def;client_secret: ghi",
+			want: "code_verifier=" + Redacted + "&refresh_token=" + Redacted + ";client_secret: " + Redacted,
+		},
+		{
+			name: "known secret",
+			in:   "upload with " + secretToken + " failed",
+			want: "upload with " + Redacted + " failed",
+		},
+		{
+			// 短い値は関係のない単語を書き換えないように伏せない
+			name: "short secret",
+			in:   "a short message",
+			want: "a short message",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.String(tt.in); got != tt.want {
+				t.Errorf("String(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactorHome(t *testing.T) {
+	home := filepath.Join(t.TempDir(), "alice")
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	path := filepath.Join(home, "Pictures", "shot.png")
+	want := filepath.Join("~", "Pictures", "shot.png")
+
+	if got := NewRedactor(nil, true).String(path); got != want {
+		t.Errorf("String(%q) = %q, want %q", path, got, want)
+	}
+	if got := NewRedactor(nil, false).String(path); got != path {
+		t.Errorf("String(%q) without redactHome = %q, want it unchanged", path, got)
+	}
+}
+
+// logWith は ReplaceAttr に r を使うハンドラーで msg と args を出力した結果を返す
+func logWith(r *Redactor, msg string, args ...any) string {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{ReplaceAttr: r.replaceAttr}))
+	logger.Info(msg, args...)
+	return buf.String()
+}
+
+func TestRedactorAttrs(t *testing.T) {
+	r := NewRedactor([]string{secretToken}, false)
+
+	type request struct {
+		URL   string
+		Token string
+	}
+	tests := []struct {
+		name string
+		args []any
+	}{
+		{name: "sensitive key", args: []any{"Token", "not-a-known-secret"}},
+		{name: "string", args: []any{"header", "Authorization: Bearer " + secretToken}},
+		{name: "error", args: []any{"error", errors.New("invalid token " + secretToken)}},
+		{name: "strings", args: []any{"args", []string{"upload", "--token", secretToken}}},
+		{name: "struct", args: []any{"request", request{URL: "https://api.gyazo.com", Token: secretToken}}},
+		{name: "pointer", args: []any{"request", &request{Token: secretToken}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := logWith(r, "test", tt.args...)
+			if strings.Contains(out, secretToken) || strings.Contains(out, "not-a-known-secret") {
+				t.Errorf("log contains the secret: %s", out)
+			}
+			if !strings.Contains(out, Redacted) {
+				t.Errorf("log does not contain %s: %s", Redacted, out)
+			}
+		})
+	}
+
+	// 伏せる値がない属性は書き換えない
+	out := logWith(r, "test", "count", 3, "request", request{URL: "https://api.gyazo.com"})
+	if !strings.Contains(out, "count=3") || !strings.Contains(out, `request="{URL:https://api.gyazo.com Token:}"`) {
+		t.Errorf("log without secrets was changed: %s", out)
+	}
+}
@@ -0,0 +1,394 @@
+package logging
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxLogSize と DefaultMaxBackupLogs は設定で指定しない場合のローテーションの設定
+	DefaultMaxLogSize    = 10 * 1024 * 1024 // 10MB
+	DefaultMaxBackupLogs = 5                // 最大5つのバックアップファイルを保持
+)
+
+// ローテーションのタイミング
+const (
+	// RotateSize はファイルが MaxSize を超える前にローテーションする
+	RotateSize = "size"
+
+	// RotateDaily は日付が変わったあとの最初の書き込みでローテーションする
+	// MaxSize を指定した場合は、サイズを超える場合もローテーションする
+	RotateDaily = "daily"
+)
+
+// compressedSuffix は圧縮したバックアップファイルの拡張子
+const compressedSuffix = ".gz"
+
+// rotatingSuffix はローテーション中のログファイルに一時的に付ける拡張子
+const rotatingSuffix = ".rotating"
+
+// RotateOptions はログファイルのローテーションの設定
+type RotateOptions struct {
+	// Rotate は RotateSize か RotateDaily
+	Rotate string
+
+	// MaxSize はログファイルの最大サイズ (バイト)。0 の場合はサイズではローテーションしない
+	MaxSize int64
+
+	// MaxBackups は残すバックアップファイルの数
+	MaxBackups int
+
+	// MaxAge より古いバックアップファイルは削除する。0 の場合は期間では削除しない
+	MaxAge time.Duration
+
+	// Compress はバックアップファイルを gzip で圧縮するかどうか
+	Compress bool
+}
+
+// DefaultRotateOptions は設定で指定しない場合のローテーションの設定を返す
+func DefaultRotateOptions() RotateOptions {
+	return RotateOptions{Rotate: RotateSize, MaxSize: DefaultMaxLogSize, MaxBackups: DefaultMaxBackupLogs}
+}
+
+// Rotator manages log file rotation
+// バックアップファイルは新しい順に "zgyazo.log.1", "zgyazo.log.2", ... (圧縮した場合は ".gz" が付く) と番号を付ける
+type Rotator struct {
+	mu      sync.Mutex
+	file    *os.File
+	logPath string
+	opts    RotateOptions
+
+	// day は現在のログファイルに書き込んでいる日 (RotateDaily で使う)
+	day string
+
+	// size は現在のログファイルのサイズ
+	size int64
+
+	// sizeLimit はローテーションに失敗したあと、次にサイズでローテーションを試みるサイズ (0 の場合は MaxSize)
+	sizeLimit int64
+
+	// onError はローテーションのエラーを報告する (nil の場合は標準エラー出力に出力する)
+	// lr.mu をロックしたまま呼ぶため、この Rotator に書き込んではいけない
+	onError func(error)
+
+	// now は現在時刻を返す (日付の判定に使う)
+	now func() time.Time
+}
+
+// NewRotator は logPath に書き込む Rotator を生成する
+func NewRotator(logPath string, opts RotateOptions) *Rotator {
+	return &Rotator{
+		logPath: logPath,
+		opts:    opts,
+		now:     time.Now,
+	}
+}
+
+// SetOptions はローテーションの設定を変更する
+// 設定ファイルの変更を反映するときに使う。古いバックアップファイルは新しい設定で削除する
+func (lr *Rotator) SetOptions(opts RotateOptions) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	lr.opts = opts
+	if err := lr.cleanupOldBackups(); err != nil {
+		lr.report(err)
+	}
+}
+
+func (lr *Rotator) Write(p []byte) (n int, err error) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	// ファイルが開いていない場合は開く
+	if lr.file == nil {
+		if err := lr.openFile(); err != nil {
+			return 0, err
+		}
+	}
+
+	if lr.shouldRotate(len(p)) {
+		if err := lr.rotate(); err != nil {
+			lr.report(fmt.Errorf("failed to rotate %s: %w", lr.logPath, err))
+		}
+		// ローテーションに失敗しても、書き込めるファイルがあればログは失わない
+		if lr.file == nil {
+			if err := lr.openFile(); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	n, err = lr.file.Write(p)
+	lr.size += int64(n)
+	return n, err
+}
+
+// shouldRotate は n バイトを書き込む前にローテーションするかどうかを返す
+func (lr *Rotator) shouldRotate(n int) bool {
+	if lr.size == 0 {
+		// 空のファイルをローテーションしても意味がない
+		return false
+	}
+	limit := lr.opts.MaxSize
+	if lr.sizeLimit > limit {
+		limit = lr.sizeLimit
+	}
+	if lr.opts.MaxSize > 0 && lr.size+int64(n) > limit {
+		return true
+	}
+	return lr.opts.Rotate == RotateDaily && lr.day != lr.today()
+}
+
+func (lr *Rotator) today() string {
+	return lr.now().Format(time.DateOnly)
+}
+
+// openFile はログファイルを開く
+// 既存のファイルの場合は最後に書き込んだ日を引き継ぎ、日付が変わっていれば次の書き込みでローテーションする
+func (lr *Rotator) openFile() error {
+	file, err := os.OpenFile(lr.logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	lr.file = file
+	lr.size = 0
+	lr.day = lr.today()
+	if info, err := file.Stat(); err == nil {
+		lr.size = info.Size()
+		if info.Size() > 0 {
+			lr.day = info.ModTime().Format(time.DateOnly)
+		}
+	}
+	return nil
+}
+
+// rotate は現在のファイルを .1 にして、新しいファイルを開く
+// 現在のファイルの名前を変えられなかった場合は、バックアップファイルには触れずに同じファイルに書き込み続け、
+// 次の日かサイズの上限をもう一度超えるまでローテーションしない
+// 名前を変えたあとは、失敗した手順があってもできるところまで続け、エラーをまとめて返す
+func (lr *Rotator) rotate() error {
+	// 現在のファイルを閉じる (Windows では開いたままのファイルの名前を変えられない)
+	var errs []error
+	if lr.file != nil {
+		if err := lr.file.Close(); err != nil {
+			errs = append(errs, err)
+		}
+		lr.file = nil
+	}
+
+	// 番号をずらす前に現在のファイルの名前を変える
+	// 先に番号をずらすと、名前を変えられない間の書き込みのたびにバックアップファイルが 1 つずつ消えてしまう
+	rotating := lr.logPath + rotatingSuffix
+	if err := os.Rename(lr.logPath, rotating); err != nil {
+		errs = append(errs, err)
+		if err := lr.openFile(); err != nil {
+			errs = append(errs, err)
+		}
+		lr.backOff()
+		return errors.Join(errs...)
+	}
+	lr.sizeLimit = 0
+
+	// 既存のバックアップファイルの番号を 1 つずつずらす
+	backups, err := lr.backups()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	for i := len(backups) - 1; i >= 0; i-- {
+		b := backups[i]
+		if err := os.Rename(b.path, lr.backupPath(b.index+1, b.compressed)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// 現在のログファイルを .1 にリネーム
+	rotated := lr.backupPath(1, false)
+	if err := os.Rename(rotating, rotated); err != nil {
+		errs = append(errs, err)
+		rotated = ""
+	}
+
+	// 新しいファイルを開く
+	if err := lr.openFile(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if rotated != "" && lr.opts.Compress {
+		if err := compressFile(rotated); err != nil {
+			errs = append(errs, fmt.Errorf("failed to compress %s: %w", rotated, err))
+		}
+	}
+
+	// 古いバックアップファイルを削除
+	if err := lr.cleanupOldBackups(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// backOff はローテーションに失敗したとき、次の日かサイズの上限をもう一度超えるまでローテーションしないようにする
+// 書き込みのたびに失敗し続けないようにするため
+func (lr *Rotator) backOff() {
+	lr.day = lr.today()
+	if lr.opts.MaxSize > 0 {
+		lr.sizeLimit = lr.size + lr.opts.MaxSize
+	}
+}
+
+// backupFile はバックアップファイル 1 つ
+type backupFile struct {
+	path       string
+	index      int
+	compressed bool
+}
+
+func (lr *Rotator) backupPath(index int, compressed bool) string {
+	path := lr.logPath + "." + strconv.Itoa(index)
+	if compressed {
+		path += compressedSuffix
+	}
+	return path
+}
+
+// backups はバックアップファイルを番号順 (新しい順) に返す
+func (lr *Rotator) backups() ([]backupFile, error) {
+	files, err := filepath.Glob(lr.logPath + ".*")
+	if err != nil {
+		return nil, err
+	}
+	var backups []backupFile
+	for _, file := range files {
+		// 番号を抽出する。"zgyazo.log.1.gz" のような圧縮したファイルも対象にする
+		name := strings.TrimPrefix(file, lr.logPath+".")
+		compressed := strings.HasSuffix(name, compressedSuffix)
+		index, err := strconv.Atoi(strings.TrimSuffix(name, compressedSuffix))
+		if err != nil || index < 1 {
+			// 圧縮中の一時ファイルなど、バックアップではないファイル
+			continue
+		}
+		backups = append(backups, backupFile{path: file, index: index, compressed: compressed})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].index < backups[j].index
+	})
+	return backups, nil
+}
+
+// cleanupOldBackups は MaxBackups を超えるバックアップファイルと、MaxAge より古いバックアップファイルを削除する
+func (lr *Rotator) cleanupOldBackups() error {
+	backups, err := lr.backups()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for i, b := range backups {
+		remove := lr.opts.MaxBackups > 0 && i >= lr.opts.MaxBackups
+		if !remove && lr.opts.MaxAge > 0 {
+			info, err := os.Stat(b.path)
+			remove = err == nil && lr.now().Sub(info.ModTime()) > lr.opts.MaxAge
+		}
+		if remove {
+			if err := os.Remove(b.path); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// compressFile は path を gzip で圧縮して path.gz に置き換える
+// 更新日時は元のファイルのものを引き継ぎ、MaxAge による削除に使う
+func compressFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	// 書き込み中に終了しても壊れたファイルが残らないよう、一時ファイルに書き込んでから置き換える
+	tmp := path + compressedSuffix + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	_, err = io.Copy(zw, in)
+	if err == nil {
+		err = zw.Close()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path+compressedSuffix)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	os.Chtimes(path+compressedSuffix, info.ModTime(), info.ModTime())
+	in.Close()
+	return os.Remove(path)
+}
+
+// report はローテーションのエラーを onError に渡す
+// ログに出力するとこの Rotator に書き込むことになり、失敗が失敗を呼ぶため標準エラー出力に出力する
+// lr.mu をロックして呼び出す
+func (lr *Rotator) report(err error) {
+	if err == nil {
+		return
+	}
+	if lr.onError == nil {
+		fmt.Fprintf(os.Stderr, "zgyazo: log rotation failed: %v\n", err)
+		return
+	}
+	lr.onError(err)
+}
+
+func (lr *Rotator) Close() error {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	if lr.file != nil {
+		return lr.file.Close()
+	}
+	return nil
+}
+
+func (lr *Rotator) Sync() error {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	if lr.file != nil {
+		return lr.file.Sync()
+	}
+	return nil
+}
+
+// Files は logPath のログファイルと、そのバックアップファイルを新しい順に返す
+// 存在しないファイルは含めない
+func Files(logPath string) ([]string, error) {
+	var files []string
+	if _, err := os.Stat(logPath); err == nil {
+		files = append(files, logPath)
+	}
+	backups, err := NewRotator(logPath, RotateOptions{}).backups()
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range backups {
+		files = append(files, b.path)
+	}
+	return files, nil
+}
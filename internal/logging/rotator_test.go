@@ -0,0 +1,191 @@
+package logging
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClock はテストで Rotator.now に設定する時計
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func newTestRotator(t *testing.T, opts RotateOptions) (*Rotator, *fakeClock) {
+	t.Helper()
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 12, 0, 0, 0, time.Local)}
+	lr := NewRotator(filepath.Join(t.TempDir(), "zgyazo.log"), opts)
+	lr.now = clock.now
+	t.Cleanup(func() { lr.Close() })
+	return lr, clock
+}
+
+func write(t *testing.T, lr *Rotator, s string) {
+	t.Helper()
+	if _, err := lr.Write([]byte(s)); err != nil {
+		t.Fatalf("Write(%q): %v", s, err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func readGzip(t *testing.T, path string) string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func assertNotExist(t *testing.T, path string) {
+	t.Helper()
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("%s should not exist (stat error: %v)", filepath.Base(path), err)
+	}
+}
+
+func TestRotateBySizeNumbersBackups(t *testing.T) {
+	lr, _ := newTestRotator(t, RotateOptions{Rotate: RotateSize, MaxSize: 10, MaxBackups: 2})
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		write(t, lr, line)
+	}
+
+	if got := readFile(t, lr.logPath); got != "fourth\n" {
+		t.Errorf("log = %q, want %q", got, "fourth\n")
+	}
+	if got := readFile(t, lr.backupPath(1, false)); got != "third\n" {
+		t.Errorf(".1 = %q, want %q", got, "third\n")
+	}
+	if got := readFile(t, lr.backupPath(2, false)); got != "second\n" {
+		t.Errorf(".2 = %q, want %q", got, "second\n")
+	}
+	assertNotExist(t, lr.backupPath(3, false))
+}
+
+func TestRotateDaily(t *testing.T) {
+	lr, clock := newTestRotator(t, RotateOptions{Rotate: RotateDaily, MaxBackups: 5})
+	write(t, lr, "day1 a\n")
+	write(t, lr, "day1 b\n")
+	assertNotExist(t, lr.backupPath(1, false))
+
+	clock.t = clock.t.AddDate(0, 0, 1)
+	write(t, lr, "day2\n")
+
+	if got := readFile(t, lr.backupPath(1, false)); got != "day1 a\nday1 b\n" {
+		t.Errorf(".1 = %q", got)
+	}
+	if got := readFile(t, lr.logPath); got != "day2\n" {
+		t.Errorf("log = %q, want %q", got, "day2\n")
+	}
+}
+
+func TestRotateCompress(t *testing.T) {
+	lr, _ := newTestRotator(t, RotateOptions{Rotate: RotateSize, MaxSize: 10, MaxBackups: 3, Compress: true})
+	for _, line := range []string{"first\n", "second\n", "third\n"} {
+		write(t, lr, line)
+	}
+
+	if got := readGzip(t, lr.backupPath(1, true)); got != "second\n" {
+		t.Errorf(".1.gz = %q, want %q", got, "second\n")
+	}
+	if got := readGzip(t, lr.backupPath(2, true)); got != "first\n" {
+		t.Errorf(".2.gz = %q, want %q", got, "first\n")
+	}
+	assertNotExist(t, lr.backupPath(1, false))
+	assertNotExist(t, lr.backupPath(1, true)+".tmp")
+}
+
+func TestCleanupMaxAge(t *testing.T) {
+	lr, clock := newTestRotator(t, RotateOptions{Rotate: RotateSize, MaxSize: 10, MaxBackups: 10})
+	for i := 1; i <= 3; i++ {
+		path := lr.backupPath(i, i == 3)
+		if err := os.WriteFile(path, []byte("old\n"), 0666); err != nil {
+			t.Fatal(err)
+		}
+		// .1 は 1 日前、.2 は 2 日前、.3.gz は 3 日前
+		mtime := clock.t.AddDate(0, 0, -i)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lr.SetOptions(RotateOptions{Rotate: RotateSize, MaxSize: 10, MaxBackups: 10, MaxAge: 36 * time.Hour})
+
+	if _, err := os.Stat(lr.backupPath(1, false)); err != nil {
+		t.Errorf(".1 should be kept: %v", err)
+	}
+	assertNotExist(t, lr.backupPath(2, false))
+	assertNotExist(t, lr.backupPath(3, true))
+}
+
+func TestRotateRenameFailureKeepsBackups(t *testing.T) {
+	lr, clock := newTestRotator(t, RotateOptions{Rotate: RotateSize, MaxSize: 10, MaxBackups: 2})
+	var reported []error
+	lr.onError = func(err error) { reported = append(reported, err) }
+	write(t, lr, "first\n")
+	write(t, lr, "second\n")
+	write(t, lr, "third\n")
+
+	// 中身のあるディレクトリには名前を変えられないため、現在のファイルのローテーションが失敗する
+	blocker := lr.logPath + rotatingSuffix
+	if err := os.MkdirAll(filepath.Join(blocker, "x"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	// 失敗したあとは、MaxSize だけ書き込むまでローテーションを試みない
+	write(t, lr, "stuck\n")
+	write(t, lr, "ab\n")
+
+	if len(reported) != 1 {
+		t.Errorf("reported %d errors, want 1 (rotation should back off): %v", len(reported), reported)
+	}
+	if got := readFile(t, lr.backupPath(1, false)); got != "second\n" {
+		t.Errorf(".1 = %q, want %q", got, "second\n")
+	}
+	if got := readFile(t, lr.backupPath(2, false)); got != "first\n" {
+		t.Errorf(".2 = %q, want %q", got, "first\n")
+	}
+	if got := readFile(t, lr.logPath); got != "third\nstuck\nab\n" {
+		t.Errorf("log = %q, want it to keep the lines written while rotation was failing", got)
+	}
+
+	// 原因が解消されると、次の日に改めてローテーションする
+	if err := os.RemoveAll(blocker); err != nil {
+		t.Fatal(err)
+	}
+	lr.opts.Rotate = RotateDaily
+	clock.t = clock.t.AddDate(0, 0, 1)
+	write(t, lr, "next day\n")
+	if got := readFile(t, lr.logPath); got != "next day\n" {
+		t.Errorf("log = %q, want %q", got, "next day\n")
+	}
+	if got := readFile(t, lr.backupPath(1, false)); !strings.HasPrefix(got, "third\n") {
+		t.Errorf(".1 = %q, want the lines written while rotation was failing", got)
+	}
+	if got := readFile(t, lr.backupPath(2, false)); got != "second\n" {
+		t.Errorf(".2 = %q, want %q", got, "second\n")
+	}
+}
@@ -0,0 +1,247 @@
+// Package metrics は常駐プロセスのメトリクスを Prometheus のテキスト形式で公開する
+//
+// 外部のライブラリには依存せず、zgyazo で使うカウンター、ゲージ、ヒストグラムだけを実装している
+// メトリクスはパッケージ変数として定義し、Default に登録する
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Default はメトリクスを登録するレジストリ
+var Default = &Registry{}
+
+// metric はレジストリに登録するメトリクス 1 つ
+type metric interface {
+	write(w io.Writer, name string)
+}
+
+// Registry は登録したメトリクスを名前順に出力する
+type Registry struct {
+	mu      sync.Mutex
+	entries []entry
+}
+
+type entry struct {
+	name, help, typ string
+	metric          metric
+}
+
+func (r *Registry) register(name, help, typ string, m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if slices.ContainsFunc(r.entries, func(e entry) bool { return e.name == name }) {
+		panic("metrics: duplicate metric " + name)
+	}
+	r.entries = append(r.entries, entry{name: name, help: help, typ: typ, metric: m})
+	sort.Slice(r.entries, func(i, j int) bool { return r.entries[i].name < r.entries[j].name })
+}
+
+// WriteTo は登録したメトリクスを Prometheus のテキスト形式で w に書き込む
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	entries := slices.Clone(r.entries)
+	r.mu.Unlock()
+
+	var b strings.Builder
+	for _, e := range entries {
+		fmt.Fprintf(&b, "# HELP %s %s\n", e.name, e.help)
+		fmt.Fprintf(&b, "# TYPE %s %s\n", e.name, e.typ)
+		e.metric.write(&b, e.name)
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// Counter は増えるだけの値
+type Counter struct {
+	mu    sync.Mutex
+	value float64
+}
+
+// NewCounter は Default に name のカウンターを登録する
+func NewCounter(name, help string) *Counter {
+	c := &Counter{}
+	Default.register(name, help, "counter", c)
+	return c
+}
+
+// Inc は 1 増やす
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add は v (0 以上) 増やす
+func (c *Counter) Add(v float64) {
+	if v < 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.value += v
+}
+
+func (c *Counter) write(w io.Writer, name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintf(w, "%s %s\n", name, formatValue(c.value))
+}
+
+// CounterVec はラベルの値ごとのカウンター
+type CounterVec struct {
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]*labeledCounter
+}
+
+type labeledCounter struct {
+	labelValues []string
+	counter     Counter
+}
+
+// NewCounterVec は Default に labels をラベルに持つ name のカウンターを登録する
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{labels: labels, values: make(map[string]*labeledCounter)}
+	Default.register(name, help, "counter", c)
+	return c
+}
+
+// With はラベルの値 (labels と同じ順) のカウンターを返す
+func (c *CounterVec) With(labelValues ...string) *Counter {
+	if len(labelValues) != len(c.labels) {
+		panic(fmt.Sprintf("metrics: got %d label values, want %d", len(labelValues), len(c.labels)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	lc, ok := c.values[key]
+	if !ok {
+		lc = &labeledCounter{labelValues: slices.Clone(labelValues)}
+		c.values[key] = lc
+	}
+	return &lc.counter
+}
+
+func (c *CounterVec) write(w io.Writer, name string) {
+	c.mu.Lock()
+	keys := make([]string, 0, len(c.values))
+	for k := range c.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	values := make([]*labeledCounter, len(keys))
+	for i, k := range keys {
+		values[i] = c.values[k]
+	}
+	c.mu.Unlock()
+
+	for _, lc := range values {
+		lc.counter.mu.Lock()
+		v := lc.counter.value
+		lc.counter.mu.Unlock()
+		fmt.Fprintf(w, "%s%s %s\n", name, formatLabels(c.labels, lc.labelValues), formatValue(v))
+	}
+}
+
+// Gauge は増減する値
+// 出力するときに値を求める場合は SetFunc を使う
+type Gauge struct {
+	mu    sync.Mutex
+	value float64
+	f     func() float64
+}
+
+// NewGauge は Default に name のゲージを登録する
+func NewGauge(name, help string) *Gauge {
+	g := &Gauge{}
+	Default.register(name, help, "gauge", g)
+	return g
+}
+
+// Set は値を v にする
+func (g *Gauge) Set(v float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.value, g.f = v, nil
+}
+
+// SetFunc は出力するたびに f を呼び出して値を求めるようにする
+func (g *Gauge) SetFunc(f func() float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.f = f
+}
+
+func (g *Gauge) write(w io.Writer, name string) {
+	g.mu.Lock()
+	v, f := g.value, g.f
+	g.mu.Unlock()
+	if f != nil {
+		v = f()
+	}
+	fmt.Fprintf(w, "%s %s\n", name, formatValue(v))
+}
+
+// Histogram は値の分布
+type Histogram struct {
+	buckets []float64
+
+	mu     sync.Mutex
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram は Default に buckets を上限 (昇順) とする name のヒストグラムを登録する
+func NewHistogram(name, help string, buckets []float64) *Histogram {
+	h := &Histogram{buckets: slices.Clone(buckets), counts: make([]uint64, len(buckets))}
+	Default.register(name, help, "histogram", h)
+	return h
+}
+
+// Observe は値 v を記録する
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, upper := range h.buckets {
+		if v <= upper {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+func (h *Histogram) write(w io.Writer, name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, upper := range h.buckets {
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", name, formatValue(upper), h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, h.count)
+	fmt.Fprintf(w, "%s_sum %s\n", name, formatValue(h.sum))
+	fmt.Fprintf(w, "%s_count %d\n", name, h.count)
+}
+
+func formatValue(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func formatLabels(names, values []string) string {
+	pairs := make([]string, len(names))
+	for i, n := range names {
+		pairs[i] = n + "=" + strconv.Quote(values[i])
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestRegistry は Default とは別のレジストリに各種類のメトリクスを登録して返す
+func newTestRegistry() (*Registry, *Counter, *CounterVec, *Gauge, *Histogram) {
+	r := &Registry{}
+	counter := &Counter{}
+	vec := &CounterVec{labels: []string{"result", "code"}, values: make(map[string]*labeledCounter)}
+	gauge := &Gauge{}
+	hist := &Histogram{buckets: []float64{0.5, 1}, counts: make([]uint64, 2)}
+	// 登録した順ではなく名前順に出力する
+	r.register("test_uploads_total", "Uploads.", "counter", vec)
+	r.register("test_files_total", "Files.", "counter", counter)
+	r.register("test_upload_seconds", "Duration.", "histogram", hist)
+	r.register("test_queue_length", "Queue.", "gauge", gauge)
+	return r, counter, vec, gauge, hist
+}
+
+func TestRegistryExposition(t *testing.T) {
+	r, counter, vec, gauge, hist := newTestRegistry()
+	counter.Inc()
+	counter.Add(2)
+	counter.Add(-1)
+	vec.With("success", "200").Inc()
+	vec.With("failure", "401").Add(2)
+	vec.With("success", "200").Inc()
+	gauge.Set(5)
+	hist.Observe(0.25)
+	hist.Observe(0.75)
+	hist.Observe(3)
+
+	var b strings.Builder
+	if _, err := r.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP test_files_total Files.
+# TYPE test_files_total counter
+test_files_total 3
+# HELP test_queue_length Queue.
+# TYPE test_queue_length gauge
+test_queue_length 5
+# HELP test_upload_seconds Duration.
+# TYPE test_upload_seconds histogram
+test_upload_seconds_bucket{le="0.5"} 1
+test_upload_seconds_bucket{le="1"} 2
+test_upload_seconds_bucket{le="+Inf"} 3
+test_upload_seconds_sum 4
+test_upload_seconds_count 3
+# HELP test_uploads_total Uploads.
+# TYPE test_uploads_total counter
+test_uploads_total{result="failure",code="401"} 2
+test_uploads_total{result="success",code="200"} 2
+`
+	if got := b.String(); got != want {
+		t.Errorf("exposition:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestGaugeSetFunc(t *testing.T) {
+	r, _, _, gauge, _ := newTestRegistry()
+	length := 1
+	gauge.SetFunc(func() float64 { return float64(length) })
+	length = 7
+
+	var b strings.Builder
+	if _, err := r.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "\ntest_queue_length 7\n") {
+		t.Errorf("gauge was not evaluated when scraped:\n%s", b.String())
+	}
+}
+
+func TestRegisterDuplicate(t *testing.T) {
+	r := &Registry{}
+	r.register("test_total", "Test.", "counter", &Counter{})
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a duplicate metric did not panic")
+		}
+	}()
+	r.register("test_total", "Test.", "counter", &Counter{})
+}
+
+func TestHandler(t *testing.T) {
+	r, counter, _, _, _ := newTestRegistry()
+	counter.Inc()
+	ts := httptest.NewServer(Handler(r))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q, want the Prometheus text format", ct)
+	}
+	if !strings.Contains(string(body), "\ntest_files_total 1\n") {
+		t.Errorf("scraped metrics do not contain the counter:\n%s", body)
+	}
+}
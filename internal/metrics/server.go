@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/zztkm/zgyazo/internal/logging"
+)
+
+var logger = logging.Logger(logging.ComponentMetrics)
+
+// Server は 127.0.0.1 で /metrics を公開する
+type Server struct {
+	srv *http.Server
+}
+
+// NewServer は 127.0.0.1:port で Default のメトリクスを公開する Server を生成する
+func NewServer(port int) *Server {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", Handler(Default))
+	return &Server{srv: &http.Server{
+		Addr:              net.JoinHostPort("127.0.0.1", strconv.Itoa(port)),
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}}
+}
+
+// Handler は r のメトリクスを返す http.Handler を返す
+func Handler(r *Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if _, err := r.WriteTo(w); err != nil {
+			logger.Debug("failed to write metrics", "error", err)
+		}
+	})
+}
+
+// Start はポートを開いて、別の goroutine でリクエストの処理を始める
+// ポートを開けない場合はエラーを返す
+func (s *Server) Start() error {
+	ln, err := net.Listen("tcp", s.srv.Addr)
+	if err != nil {
+		return err
+	}
+	logger.Info("metrics server started", "addr", ln.Addr().String())
+	go func() {
+		if err := s.srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("metrics server stopped", "error", err)
+		}
+	}()
+	return nil
+}
+
+// Shutdown は処理中のリクエストを待ってからサーバーを停止する
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
+}
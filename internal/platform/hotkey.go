@@ -0,0 +1,85 @@
+package platform
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Hotkey はホットキーと、押されたときに呼び出す処理の組み合わせ
+type Hotkey struct {
+	// Keys は "Ctrl+Shift+C" のような修飾キーとキーの組み合わせ
+	Keys string
+
+	// Action はホットキーが押されたときに呼び出される
+	Action func()
+}
+
+// KeyCombo は Hotkey.Keys を解析した結果
+type KeyCombo struct {
+	Ctrl  bool
+	Shift bool
+	Alt   bool
+	Win   bool
+
+	// Key は修飾キー以外のキー ("A"〜"Z", "0"〜"9", "F1"〜"F24")
+	Key string
+}
+
+// ParseKeys は "Ctrl+Shift+C" のような文字列を解析する
+// 修飾キーを含まないホットキーは通常の入力を奪ってしまうため受け付けない
+func ParseKeys(keys string) (KeyCombo, error) {
+	var combo KeyCombo
+	for _, part := range strings.Split(keys, "+") {
+		part = strings.TrimSpace(part)
+		switch strings.ToLower(part) {
+		case "ctrl", "control":
+			combo.Ctrl = true
+		case "shift":
+			combo.Shift = true
+		case "alt":
+			combo.Alt = true
+		case "win", "super":
+			combo.Win = true
+		default:
+			if combo.Key != "" {
+				return KeyCombo{}, fmt.Errorf("hotkey %q has more than one key", keys)
+			}
+			key := strings.ToUpper(part)
+			if !isSupportedKey(key) {
+				return KeyCombo{}, fmt.Errorf("hotkey %q has unsupported key %q", keys, part)
+			}
+			combo.Key = key
+		}
+	}
+	if combo.Key == "" {
+		return KeyCombo{}, fmt.Errorf("hotkey %q has no key", keys)
+	}
+	if !combo.Ctrl && !combo.Shift && !combo.Alt && !combo.Win {
+		return KeyCombo{}, fmt.Errorf("hotkey %q needs at least one modifier", keys)
+	}
+	return combo, nil
+}
+
+func isSupportedKey(key string) bool {
+	if len(key) == 1 {
+		c := key[0]
+		return ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+	}
+	if n, ok := functionKeyNumber(key); ok {
+		return 1 <= n && n <= 24
+	}
+	return false
+}
+
+// functionKeyNumber は "F1"〜"F24" の番号を返す
+func functionKeyNumber(key string) (int, bool) {
+	if !strings.HasPrefix(key, "F") {
+		return 0, false
+	}
+	n, err := strconv.Atoi(key[1:])
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
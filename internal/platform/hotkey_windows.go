@@ -0,0 +1,343 @@
+package platform
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// Windows APIの定数を定義
+const (
+	// 修飾キー
+	MOD_ALT      = 0x0001
+	MOD_CONTROL  = 0x0002
+	MOD_SHIFT    = 0x0004
+	MOD_WIN      = 0x0008
+	MOD_NOREPEAT = 0x4000 // ホットキーの自動リピートを防ぐ
+
+	// Windowsメッセージ
+	WM_HOTKEY = 0x0312
+	WM_APP    = 0x8000
+
+	// WM_RELOAD_HOTKEYS は Update がメッセージループにホットキーの登録し直しを依頼するメッセージ
+	WM_RELOAD_HOTKEYS = WM_APP + 1
+
+	// 仮想キーコード (F1キー)
+	// A〜Z と 0〜9 の仮想キーコードは ASCII コードと同じ
+	VK_F1 = 0x70
+)
+
+// user32.dll とその中の関数をロード
+var (
+	user32               = windows.NewLazySystemDLL("user32.dll")
+	procRegisterHotKey   = user32.NewProc("RegisterHotKey")
+	procUnregisterHotKey = user32.NewProc("UnregisterHotKey")
+	procGetMessage       = user32.NewProc("GetMessageW")
+	procCreateWindowEx   = user32.NewProc("CreateWindowExW")
+	procDefWindowProc    = user32.NewProc("DefWindowProcW")
+	procRegisterClass    = user32.NewProc("RegisterClassW")
+	procPostMessage      = user32.NewProc("PostMessageW")
+)
+
+// kernel32.dll
+var (
+	kernel32            = windows.NewLazySystemDLL("kernel32.dll")
+	procGetModuleHandle = kernel32.NewProc("GetModuleHandleW")
+)
+
+// メッセージ処理用の定数
+const (
+	WM_QUIT = 0x0012
+)
+
+// windowsHotkeyService は RegisterHotKey でホットキーを登録する HotkeyService
+type windowsHotkeyService struct {
+	mu      sync.Mutex
+	hWnd    uintptr
+	stopped bool
+	// pending は Update で渡され、メッセージループで登録されるのを待っているホットキー
+	pending []Hotkey
+
+	// current は登録済みのホットキー。メッセージループのスレッドからだけ触る
+	current []Hotkey
+}
+
+func (s *windowsHotkeyService) Run(hotkeys []Hotkey) error {
+	// ホットキーとメッセージウィンドウはスレッドに紐づくため、同じ OS スレッドで処理する
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	// 専用のメッセージウィンドウを作成
+	hWnd := createMessageWindow()
+	if hWnd == 0 {
+		return errors.New("failed to create message window")
+	}
+	hotkeyLogger.Debug("message window created", "hwnd", fmt.Sprintf("%x", hWnd))
+
+	actions := make(map[uintptr]func(), len(hotkeys))
+	// プログラム終了時にホットキーを解除する
+	defer unregisterHotkeys(hWnd, actions)
+	if err := registerHotkeys(hWnd, hotkeys, actions); err != nil {
+		return err
+	}
+	s.current = hotkeys
+
+	s.mu.Lock()
+	if s.stopped {
+		// メッセージループ開始前に Stop が呼ばれていた
+		s.mu.Unlock()
+		return nil
+	}
+	s.hWnd = hWnd
+	s.mu.Unlock()
+
+	// 改善されたメッセージループを開始
+	runImprovedMessageLoop(hWnd, actions, func() { s.reload(hWnd, actions) })
+	hotkeyLogger.Debug("message loop ended")
+	return nil
+}
+
+// Update は登録するホットキーを置き換える
+// ホットキーは登録したスレッドでしか解除できないため、メッセージループに登録し直しを依頼する
+func (s *windowsHotkeyService) Update(hotkeys []Hotkey) error {
+	for _, hotkey := range hotkeys {
+		if _, err := ParseKeys(hotkey.Keys); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.hWnd == 0 || s.stopped {
+		return errors.New("hotkey service is not running")
+	}
+	s.pending = hotkeys
+	if ret, _, err := procPostMessage.Call(s.hWnd, WM_RELOAD_HOTKEYS, 0, 0); ret == 0 {
+		return fmt.Errorf("PostMessage failed: %w", err)
+	}
+	return nil
+}
+
+// reload は Update で渡されたホットキーを登録し直す
+// 登録に失敗した場合は元のホットキーに戻す
+func (s *windowsHotkeyService) reload(hWnd uintptr, actions map[uintptr]func()) {
+	s.mu.Lock()
+	hotkeys := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+	if hotkeys == nil {
+		return
+	}
+
+	unregisterHotkeys(hWnd, actions)
+	if err := registerHotkeys(hWnd, hotkeys, actions); err != nil {
+		hotkeyLogger.Error("failed to update hotkeys, restoring previous hotkeys", "error", err)
+		unregisterHotkeys(hWnd, actions)
+		if err := registerHotkeys(hWnd, s.current, actions); err != nil {
+			hotkeyLogger.Error("failed to restore hotkeys", "error", err)
+		}
+		return
+	}
+	s.current = hotkeys
+}
+
+// registerHotkeys は hotkeys を登録し、ホットキーID と Action の対応を actions に追加する
+// ホットキーID（プログラム内でユニークであれば何でも良い）は hotkeys のインデックス + 1 とする
+func registerHotkeys(hWnd uintptr, hotkeys []Hotkey, actions map[uintptr]func()) error {
+	for i, hotkey := range hotkeys {
+		combo, err := ParseKeys(hotkey.Keys)
+		if err != nil {
+			return err
+		}
+		id := uintptr(i + 1)
+
+		// RegisterHotKey(hWnd, id, fsModifiers, vk)
+		// hWnd:      専用ウィンドウのハンドル
+		// id:        ホットキーのID
+		// fsModifiers: 修飾キーの組み合わせ
+		// vk:          仮想キーコード
+		ret, _, err := procRegisterHotKey.Call(
+			hWnd,                      // 専用ウィンドウのハンドル
+			id,                        // id
+			modifiers(combo),          // fsModifiers (MOD_NOREPEATを追加)
+			virtualKeyCode(combo.Key), // vk
+		)
+		// retが0の場合は登録失敗
+		if ret == 0 {
+			return fmt.Errorf("RegisterHotKey %s failed: %w", hotkey.Keys, err)
+		}
+		actions[id] = hotkey.Action
+		hotkeyLogger.Info("registered hotkey", "keys", hotkey.Keys)
+	}
+	return nil
+}
+
+// unregisterHotkeys は actions のホットキーをすべて解除する
+func unregisterHotkeys(hWnd uintptr, actions map[uintptr]func()) {
+	for id := range actions {
+		procUnregisterHotKey.Call(hWnd, id)
+		delete(actions, id)
+	}
+}
+
+// CheckHotkey は keys のホットキーを登録できるかを、登録してすぐに解除して確かめる
+// 他のアプリ (起動中の zgyazo を含む) が使っている場合はエラーを返す
+func CheckHotkey(keys string) error {
+	combo, err := ParseKeys(keys)
+	if err != nil {
+		return err
+	}
+	// ウィンドウを指定しない場合、ホットキーは登録したスレッドに紐づく
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	const checkID = 0xBFFF
+	ret, _, err := procRegisterHotKey.Call(0, checkID, modifiers(combo), virtualKeyCode(combo.Key))
+	if ret == 0 {
+		return fmt.Errorf("RegisterHotKey %s failed: %w", keys, err)
+	}
+	procUnregisterHotKey.Call(0, checkID)
+	return nil
+}
+
+// Stop はメッセージウィンドウに WM_QUIT を送ってメッセージループを終了させる
+func (s *windowsHotkeyService) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopped = true
+	if s.hWnd != 0 {
+		procPostMessage.Call(s.hWnd, WM_QUIT, 0, 0)
+	}
+}
+
+// modifiers は RegisterHotKey の fsModifiers を返す
+func modifiers(combo KeyCombo) uintptr {
+	// ホットキーの自動リピートを防ぐため MOD_NOREPEAT は常に付ける
+	var mods uintptr = MOD_NOREPEAT
+	if combo.Ctrl {
+		mods |= MOD_CONTROL
+	}
+	if combo.Shift {
+		mods |= MOD_SHIFT
+	}
+	if combo.Alt {
+		mods |= MOD_ALT
+	}
+	if combo.Win {
+		mods |= MOD_WIN
+	}
+	return mods
+}
+
+// virtualKeyCode は ParseKeys で検証済みのキーの仮想キーコードを返す
+func virtualKeyCode(key string) uintptr {
+	if n, ok := functionKeyNumber(key); ok {
+		return uintptr(VK_F1 + n - 1)
+	}
+	return uintptr(key[0])
+}
+
+// 専用のメッセージウィンドウを作成
+func createMessageWindow() uintptr {
+	// より簡単な方法：既存のウィンドウクラスを使用
+	className := windows.StringToUTF16Ptr("STATIC")
+	windowName := windows.StringToUTF16Ptr("ZgyazoMessageWindow")
+
+	hInstance, _, _ := procGetModuleHandle.Call(0)
+
+	// HWND_MESSAGE を使用してメッセージ専用ウィンドウを作成
+	const HWND_MESSAGE = ^uintptr(2) // -3 in uintptr
+	hWnd, _, err := procCreateWindowEx.Call(
+		0,                                   // dwExStyle
+		uintptr(unsafe.Pointer(className)),  // lpClassName (既存のSTATICクラスを使用)
+		uintptr(unsafe.Pointer(windowName)), // lpWindowName
+		0,                                   // dwStyle (非表示)
+		0, 0, 0, 0,                          // x, y, width, height
+		HWND_MESSAGE, // hWndParent (メッセージ専用ウィンドウ)
+		0,            // hMenu
+		hInstance,    // hInstance
+		0,            // lpParam
+	)
+
+	if hWnd == 0 {
+		hotkeyLogger.Warn("CreateWindowEx failed, creating a normal hidden window instead", "error", err)
+		// フォールバック：通常の非表示ウィンドウを作成
+		hWnd, _, err = procCreateWindowEx.Call(
+			0,                                   // dwExStyle
+			uintptr(unsafe.Pointer(className)),  // lpClassName
+			uintptr(unsafe.Pointer(windowName)), // lpWindowName
+			0,                                   // dwStyle (非表示)
+			uintptr(^uint32(999)),               // x (画面外の負の値)
+			uintptr(^uint32(999)),               // y (画面外の負の値)
+			1,                                   // width
+			1,                                   // height
+			0,                                   // hWndParent
+			0,                                   // hMenu
+			hInstance,                           // hInstance
+			0,                                   // lpParam
+		)
+
+		if hWnd == 0 {
+			hotkeyLogger.Error("fallback CreateWindowEx also failed", "error", err)
+			return 0
+		}
+	}
+
+	return hWnd
+}
+
+// 改善されたメッセージループ
+// WM_RELOAD_HOTKEYS を受け取ると onReload を呼び出す
+func runImprovedMessageLoop(hWnd uintptr, actions map[uintptr]func(), onReload func()) {
+	var msg struct {
+		HWnd    uintptr
+		Message uint32
+		WParam  uintptr
+		LParam  uintptr
+		Time    uint32
+		Pt      struct{ X, Y int32 }
+	}
+
+	hotkeyLogger.Debug("starting message loop", "hwnd", fmt.Sprintf("%x", hWnd))
+
+	// GetMessageを使用したメッセージループ
+	// メッセージごとにはログを出力しない (ホットキーとリロード以外のメッセージも届くため)
+	for {
+		// GetMessageはメッセージが来るまでブロックする
+		// 戻り値: >0 = メッセージあり, 0 = WM_QUIT, -1 = エラー
+		ret, _, err := procGetMessage.Call(
+			uintptr(unsafe.Pointer(&msg)),
+			hWnd, // このウィンドウのメッセージのみを取得
+			0,    // wMsgFilterMin
+			0,    // wMsgFilterMax
+		)
+
+		// エラーチェック
+		if ret == uintptr(^uint32(0)) { // -1
+			hotkeyLogger.Error("GetMessage failed", "error", err)
+			continue
+		}
+
+		// WM_QUITメッセージを受信した場合
+		if ret == 0 {
+			hotkeyLogger.Debug("WM_QUIT received, exiting message loop")
+			break
+		}
+
+		if msg.Message == WM_HOTKEY {
+			// どのホットキーが押されたかIDで確認
+			if action, ok := actions[msg.WParam]; ok {
+				hotkeyLogger.Debug("hotkey pressed", "id", msg.WParam)
+				action()
+			} else {
+				hotkeyLogger.Debug("unknown hotkey ID", "id", msg.WParam)
+			}
+		} else if msg.Message == WM_RELOAD_HOTKEYS {
+			hotkeyLogger.Debug("re-registering hotkeys")
+			onReload()
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package platform
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// ConfigDir は config.json などの設定ファイルを置くディレクトリを返す
+func ConfigDir() string {
+	// Linux では $XDG_CONFIG_HOME/zgyazo/ (未設定なら ~/.config/zgyazo/)
+	return filepath.Join(xdgDir("XDG_CONFIG_HOME", ".config"), "zgyazo")
+}
+
+// StateDir はログなどアプリケーションの状態を置くディレクトリを返す
+func StateDir() string {
+	// Linux では $XDG_STATE_HOME/zgyazo/ (未設定なら ~/.local/state/zgyazo/)
+	return filepath.Join(xdgDir("XDG_STATE_HOME", filepath.Join(".local", "state")), "zgyazo")
+}
+
+// xdgDir は XDG Base Directory の環境変数を解決する
+// 仕様により、環境変数が空または相対パスの場合はホームディレクトリ配下のデフォルトを使う
+func xdgDir(env string, fallback string) string {
+	if dir := os.Getenv(env); filepath.IsAbs(dir) {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return fallback
+	}
+	return filepath.Join(home, fallback)
+}
+
+// CaptureDirCandidates はキャプチャツールが画像を保存するディレクトリの候補を返す
+// GNOME のスクリーンショットは ~/Pictures/Screenshots に保存される
+func CaptureDirCandidates() []string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	pictures := filepath.Join(home, "Pictures")
+	if dir := os.Getenv("XDG_PICTURES_DIR"); filepath.IsAbs(dir) {
+		pictures = dir
+	}
+	return []string{
+		filepath.Join(pictures, "Screenshots"),
+		pictures,
+	}
+}
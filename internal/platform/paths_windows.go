@@ -0,0 +1,32 @@
+package platform
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// ConfigDir は config.json などの設定ファイルを置くディレクトリを返す
+func ConfigDir() string {
+	// Windows では %APPDATA%/zgyazo/
+	return filepath.Join(os.Getenv("APPDATA"), "zgyazo")
+}
+
+// StateDir はログなどアプリケーションの状態を置くディレクトリを返す
+func StateDir() string {
+	// Windows では設定もログも %APPDATA%/zgyazo/ に置く
+	return ConfigDir()
+}
+
+// CaptureDirCandidates はキャプチャツールが画像を保存するディレクトリの候補を返す
+// Windows 11 の Snipping Tool は既定で ピクチャ\Screenshots に自動保存する
+func CaptureDirCandidates() []string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	return []string{
+		filepath.Join(home, "Pictures", "Screenshots"),
+		filepath.Join(home, "OneDrive", "Pictures", "Screenshots"),
+		filepath.Join(home, "Pictures", "Snipping Tool"),
+	}
+}
@@ -0,0 +1,75 @@
+// Package platform は OS ごとに異なる機能 (URL を開く、ホットキー、キャプチャツールの起動、通知、
+// 設定やログの置き場所) を抽象化する
+package platform
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/zztkm/zgyazo/internal/logging"
+)
+
+var (
+	logger       = logging.Logger(logging.ComponentPlatform)
+	hotkeyLogger = logging.Logger(logging.ComponentHotkey)
+)
+
+// ErrHotkeysUnsupported はグローバルホットキーに対応していない OS であることを表す
+var ErrHotkeysUnsupported = errors.New("global hotkeys are not supported on this platform")
+
+// URLOpener はアップロードした画像の URL を開く
+type URLOpener interface {
+	Open(url string) error
+}
+
+// HotkeyService はグローバルホットキーを監視する
+// Run は hotkeys を登録し、ホットキーが押されるたびに対応する Action を呼び出し、Stop が呼ばれるまでブロックする
+// Update は Run の実行中に登録するホットキーを hotkeys に置き換える
+type HotkeyService interface {
+	Run(hotkeys []Hotkey) error
+	Update(hotkeys []Hotkey) error
+	Stop()
+}
+
+// CaptureLauncher は画面キャプチャツールを起動する
+type CaptureLauncher interface {
+	Launch() error
+}
+
+// Notifier はアップロード結果などをユーザーに通知する
+type Notifier interface {
+	Notify(title string, message string) error
+}
+
+// Platform は OS ごとの実装をまとめたもの
+// New は OS ごとのファイルで定義されている
+type Platform struct {
+	Opener   URLOpener
+	Hotkeys  HotkeyService
+	Launcher CaptureLauncher
+	Notifier Notifier
+}
+
+// EnsureDirs は設定ディレクトリとログなどの状態ディレクトリが存在しない場合は作成する
+// Windows ではどちらも %APPDATA%/zgyazo/ になる
+func EnsureDirs() error {
+	if err := os.MkdirAll(ConfigDir(), 0755); err != nil {
+		return err
+	}
+	return os.MkdirAll(StateDir(), 0755)
+}
+
+// LogPath はログファイルのパスを返す
+func LogPath() string {
+	return filepath.Join(StateDir(), "zgyazo.log")
+}
+
+// LogNotifier は通知をログに出力するだけの Notifier
+// デスクトップ通知の仕組みがない環境で使う
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(title string, message string) error {
+	logger.Info("notify", "title", title, "message", message)
+	return nil
+}
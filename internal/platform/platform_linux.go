@@ -0,0 +1,74 @@
+package platform
+
+import (
+	"errors"
+	"os/exec"
+	"sync"
+)
+
+// New は実行中の OS 向けの Platform を生成する
+func New() *Platform {
+	var notifier Notifier = LogNotifier{}
+	if _, err := exec.LookPath("notify-send"); err == nil {
+		notifier = notifySendNotifier{}
+	}
+	return &Platform{
+		Opener:   xdgOpener{},
+		Hotkeys:  newNoopHotkeyService(),
+		Launcher: unsupportedCaptureLauncher{},
+		Notifier: notifier,
+	}
+}
+
+// xdgOpener は xdg-open でデフォルトのアプリケーションを使って URL を開く
+type xdgOpener struct{}
+
+func (xdgOpener) Open(url string) error {
+	return exec.Command("xdg-open", url).Start()
+}
+
+// noopHotkeyService は Linux 用の HotkeyService でホットキーを登録しない
+// グローバルホットキーはデスクトップ環境ごとに仕組みが異なるため、
+// Linux ではデスクトップ環境側のショートカット設定からキャプチャツールを起動してもらう
+type noopHotkeyService struct {
+	stopOnce sync.Once
+	stopCh   chan struct{}
+}
+
+func newNoopHotkeyService() *noopHotkeyService {
+	return &noopHotkeyService{stopCh: make(chan struct{})}
+}
+
+func (s *noopHotkeyService) Run(hotkeys []Hotkey) error {
+	hotkeyLogger.Info("hotkeys are not supported on Linux, running as watcher daemon only")
+	<-s.stopCh
+	return nil
+}
+
+func (s *noopHotkeyService) Update(hotkeys []Hotkey) error {
+	return nil
+}
+
+func (s *noopHotkeyService) Stop() {
+	s.stopOnce.Do(func() { close(s.stopCh) })
+}
+
+// CheckHotkey は Linux ではホットキーを登録しないため、常に ErrHotkeysUnsupported を返す
+func CheckHotkey(keys string) error {
+	return ErrHotkeysUnsupported
+}
+
+// unsupportedCaptureLauncher は Linux 用の CaptureLauncher
+// ホットキーがないため呼ばれることはない
+type unsupportedCaptureLauncher struct{}
+
+func (unsupportedCaptureLauncher) Launch() error {
+	return errors.New("launching a capture tool is not supported on this platform")
+}
+
+// notifySendNotifier は notify-send でデスクトップ通知を表示する
+type notifySendNotifier struct{}
+
+func (notifySendNotifier) Notify(title string, message string) error {
+	return exec.Command("notify-send", "--app-name=zgyazo", title, message).Run()
+}
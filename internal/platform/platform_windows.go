@@ -0,0 +1,49 @@
+package platform
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// New は実行中の OS 向けの Platform を生成する
+func New() *Platform {
+	return &Platform{
+		Opener:   browserOpener{},
+		Hotkeys:  &windowsHotkeyService{},
+		Launcher: snippingToolLauncher{},
+		Notifier: LogNotifier{},
+	}
+}
+
+// browserOpener は start コマンドでデフォルトのブラウザを使って URL を開く
+type browserOpener struct{}
+
+func (browserOpener) Open(url string) error {
+	return exec.Command("cmd", "/c", "start", url).Start()
+}
+
+// snippingToolLauncher は Snipping Tool を起動する CaptureLauncher
+type snippingToolLauncher struct{}
+
+func (snippingToolLauncher) Launch() error {
+	// 既存のSnipping Toolプロセスをチェック
+	checkCmd := exec.Command("tasklist", "/FI", "IMAGENAME eq SnippingTool.exe")
+	checkOutput, _ := checkCmd.Output()
+	logger.Debug("existing Snipping Tool processes", "tasklist", string(checkOutput))
+
+	// Snipping Toolを起動する
+	// NOTE: Windows 11 では動作チェックをした
+	// TODO: snippingtool 以外のアプリも起動できるようにしたい
+	cmd := exec.Command("snippingtool.exe")
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("Snipping Toolの起動に失敗しました: %w", err)
+	}
+
+	// プロセスの完了を待機
+	if err := cmd.Wait(); err != nil {
+		logger.Debug("Snipping Tool finished with error", "error", err)
+	} else {
+		logger.Debug("Snipping Tool finished")
+	}
+	return nil
+}
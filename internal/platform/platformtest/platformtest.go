@@ -0,0 +1,175 @@
+// Package platformtest は platform パッケージのインターフェースの fake 実装を提供する
+// OS の機能を使わずに、検出 → アップロード → URL を開く までの流れを確認するためのもの
+package platformtest
+
+import (
+	"sync"
+
+	"github.com/zztkm/zgyazo/internal/platform"
+)
+
+var (
+	_ platform.URLOpener       = (*URLOpener)(nil)
+	_ platform.HotkeyService   = (*HotkeyService)(nil)
+	_ platform.CaptureLauncher = (*CaptureLauncher)(nil)
+	_ platform.Notifier        = (*Notifier)(nil)
+)
+
+// openedBufferSize は URLOpener.Opened で受け取れる URL の数
+const openedBufferSize = 100
+
+// URLOpener は開いた URL を記録する platform.URLOpener
+type URLOpener struct {
+	mu     sync.Mutex
+	urls   []string
+	opened chan string
+}
+
+// NewURLOpener は URLOpener を生成する
+func NewURLOpener() *URLOpener {
+	return &URLOpener{opened: make(chan string, openedBufferSize)}
+}
+
+func (o *URLOpener) Open(url string) error {
+	o.mu.Lock()
+	o.urls = append(o.urls, url)
+	o.mu.Unlock()
+	select {
+	case o.opened <- url:
+	default:
+	}
+	return nil
+}
+
+// Opened は開かれた URL を順に受け取るチャネルを返す
+func (o *URLOpener) Opened() <-chan string {
+	return o.opened
+}
+
+// URLs はこれまでに開かれた URL を返す
+func (o *URLOpener) URLs() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]string(nil), o.urls...)
+}
+
+// HotkeyService は Trigger でホットキーの押下を再現する platform.HotkeyService
+type HotkeyService struct {
+	mu      sync.Mutex
+	hotkeys []platform.Hotkey
+
+	triggerCh chan string
+	stopOnce  sync.Once
+	stopCh    chan struct{}
+}
+
+// NewHotkeyService は HotkeyService を生成する
+func NewHotkeyService() *HotkeyService {
+	return &HotkeyService{
+		triggerCh: make(chan string),
+		stopCh:    make(chan struct{}),
+	}
+}
+
+func (s *HotkeyService) Run(hotkeys []platform.Hotkey) error {
+	s.Update(hotkeys)
+	for {
+		select {
+		case keys := <-s.triggerCh:
+			s.mu.Lock()
+			hotkeys := s.hotkeys
+			s.mu.Unlock()
+			for _, hotkey := range hotkeys {
+				if hotkey.Keys == keys {
+					hotkey.Action()
+				}
+			}
+		case <-s.stopCh:
+			return nil
+		}
+	}
+}
+
+func (s *HotkeyService) Update(hotkeys []platform.Hotkey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hotkeys = append([]platform.Hotkey(nil), hotkeys...)
+	return nil
+}
+
+// Keys は登録されているホットキーを返す
+func (s *HotkeyService) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, len(s.hotkeys))
+	for i, hotkey := range s.hotkeys {
+		keys[i] = hotkey.Keys
+	}
+	return keys
+}
+
+func (s *HotkeyService) Stop() {
+	s.stopOnce.Do(func() { close(s.stopCh) })
+}
+
+// Trigger は keys のホットキーが押されたものとして Run に渡された Action を呼び出す
+func (s *HotkeyService) Trigger(keys string) {
+	select {
+	case s.triggerCh <- keys:
+	case <-s.stopCh:
+	}
+}
+
+// CaptureLauncher は起動された回数を数え、設定された関数を呼び出す platform.CaptureLauncher
+// OnLaunch で監視ディレクトリにファイルを書き込めば、キャプチャツールの保存を再現できる
+type CaptureLauncher struct {
+	// OnLaunch は Launch のたびに呼び出される (nil の場合は何もしない)
+	OnLaunch func() error
+
+	mu       sync.Mutex
+	launches int
+}
+
+func (l *CaptureLauncher) Launch() error {
+	l.mu.Lock()
+	l.launches++
+	onLaunch := l.OnLaunch
+	l.mu.Unlock()
+	if onLaunch != nil {
+		return onLaunch()
+	}
+	return nil
+}
+
+// Launches は Launch が呼ばれた回数を返す
+func (l *CaptureLauncher) Launches() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.launches
+}
+
+// Notification は Notifier が受け取った通知
+type Notification struct {
+	Title   string
+	Message string
+}
+
+// Notifier は通知を記録する platform.Notifier
+type Notifier struct {
+	mu            sync.Mutex
+	notifications []Notification
+}
+
+func (n *Notifier) Notify(title string, message string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.notifications = append(n.notifications, Notification{Title: title, Message: message})
+	return nil
+}
+
+// Notifications はこれまでに受け取った通知を返す
+func (n *Notifier) Notifications() []Notification {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]Notification(nil), n.notifications...)
+}
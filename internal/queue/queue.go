@@ -0,0 +1,75 @@
+// Package queue はアップロードを待っている画像を保存する
+// 常駐プロセスが一時停止中や、アップロードする前に終了した画像を、次の起動時にアップロードし直すために使う
+package queue
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/zztkm/zgyazo/internal/jsonstore"
+	"github.com/zztkm/zgyazo/internal/platform"
+)
+
+// Entry はアップロードを待っている画像 1 件
+type Entry struct {
+	FilePath string    `json:"file_path"`
+	QueuedAt time.Time `json:"queued_at"`
+}
+
+// Store はアップロードを待っている画像を JSON ファイルに保存する
+// 一時停止中に保存した画像を失わないように、件数の上限は設けない
+// 複数の goroutine から同時に使ってよい
+type Store struct {
+	store *jsonstore.Store[Entry]
+}
+
+// DefaultPath はファイルのパスを返す
+func DefaultPath() string {
+	return filepath.Join(platform.StateDir(), "queue.json")
+}
+
+// Open は path のファイルを読み込む
+// ファイルが存在しない場合は空として扱い、最初の書き込みで作成する
+func Open(path string) (*Store, error) {
+	store, err := jsonstore.Open[Entry](path, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{store: store}, nil
+}
+
+// Add は filePath を追加して保存する
+// 同じファイルがすでにある場合は何もしない
+func (s *Store) Add(filePath string) error {
+	return s.store.Update(func(entries []Entry) ([]Entry, bool) {
+		for _, e := range entries {
+			if e.FilePath == filePath {
+				return entries, false
+			}
+		}
+		return append(entries, Entry{FilePath: filePath, QueuedAt: time.Now()}), true
+	})
+}
+
+// Entries は古い順にすべての画像を返す
+func (s *Store) Entries() []Entry {
+	return s.store.Entries()
+}
+
+// Len は保存している件数を返す
+func (s *Store) Len() int {
+	return s.store.Len()
+}
+
+// Remove は filePath の画像を削除して保存する
+// アップロードに成功した場合や、諦めた場合に使う
+func (s *Store) Remove(filePath string) error {
+	return s.store.Update(func(entries []Entry) ([]Entry, bool) {
+		for i, e := range entries {
+			if e.FilePath == filePath {
+				return append(entries[:i], entries[i+1:]...), true
+			}
+		}
+		return entries, false
+	})
+}
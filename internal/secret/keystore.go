@@ -0,0 +1,235 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/zztkm/zgyazo/internal/platform"
+)
+
+// keystoreVersion はキーストアのファイル形式のバージョン
+const keystoreVersion = 1
+
+// keySize は AES-256 の鍵の長さ
+const keySize = 32
+
+// ErrNotFound はキーストアに指定した名前の値がないことを表す
+var ErrNotFound = errors.New("secret not found in keystore")
+
+// Keystore は AES-256-GCM で暗号化した値を保存するファイル
+// 暗号化の鍵はキーストアとは別のファイルに保存する
+// Windows では鍵を DPAPI で保護し、同じユーザーでしか復号できないようにする
+// Linux では所有者だけが読めるパーミッションで保存する
+type Keystore struct {
+	path    string
+	keyPath string
+}
+
+// keystoreFile はキーストアのファイルの内容
+type keystoreFile struct {
+	Version int `json:"version"`
+
+	// Secrets は名前と、nonce と暗号文をつないで base64 にした値の対応
+	Secrets map[string]string `json:"secrets"`
+}
+
+// DefaultKeystore は設定ディレクトリのキーストアを返す
+// 設定ディレクトリを同期しても鍵は同期されないよう、鍵は状態ディレクトリに置く
+func DefaultKeystore() *Keystore {
+	return NewKeystore(
+		filepath.Join(platform.ConfigDir(), "keystore.json"),
+		filepath.Join(platform.StateDir(), "keystore.key"),
+	)
+}
+
+// NewKeystore は path に保存し、keyPath の鍵で暗号化する Keystore を返す
+func NewKeystore(path string, keyPath string) *Keystore {
+	return &Keystore{path: path, keyPath: keyPath}
+}
+
+// Path はキーストアのファイルのパスを返す
+func (k *Keystore) Path() string {
+	return k.path
+}
+
+// Get は name の値を復号して返す
+func (k *Keystore) Get(name string) (string, error) {
+	f, err := k.load()
+	if err != nil {
+		return "", err
+	}
+	encoded, ok := f.Secrets[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s (add it with 'zgyazo secret set %s')", ErrNotFound, name, name)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("keystore entry %s is corrupted: %w", name, err)
+	}
+
+	key, err := k.loadKey(false)
+	if err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("keystore entry %s is corrupted", name)
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	// 名前を追加データにして、別の名前の値と入れ替えられても復号できないようにする
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(name))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt keystore entry %s (was the key replaced?): %w", name, err)
+	}
+	return string(plaintext), nil
+}
+
+// Set は value を暗号化して name として保存する
+// 鍵がない場合は作成する
+func (k *Keystore) Set(name string, value string) error {
+	if name == "" {
+		return errors.New("secret name must not be empty")
+	}
+	f, err := k.load()
+	if err != nil {
+		return err
+	}
+	key, err := k.loadKey(true)
+	if err != nil {
+		return err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(value), []byte(name))
+	f.Secrets[name] = base64.StdEncoding.EncodeToString(sealed)
+	return k.save(f)
+}
+
+// Delete は name の値を削除する
+func (k *Keystore) Delete(name string) error {
+	f, err := k.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := f.Secrets[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	delete(f.Secrets, name)
+	return k.save(f)
+}
+
+// Names は保存されている値の名前を名前順に返す
+func (k *Keystore) Names() ([]string, error) {
+	f, err := k.load()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(f.Secrets))
+	for name := range f.Secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// load はキーストアを読み込む。ファイルがない場合は空のキーストアを返す
+func (k *Keystore) load() (*keystoreFile, error) {
+	f := &keystoreFile{Version: keystoreVersion, Secrets: map[string]string{}}
+	data, err := os.ReadFile(k.path)
+	if os.IsNotExist(err) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("%s: %w", k.path, err)
+	}
+	if f.Version != keystoreVersion {
+		return nil, fmt.Errorf("%s: unsupported keystore version %d", k.path, f.Version)
+	}
+	if f.Secrets == nil {
+		f.Secrets = map[string]string{}
+	}
+	return f, nil
+}
+
+func (k *Keystore) save(f *keystoreFile) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(k.path, append(data, '\n'))
+}
+
+// loadKey は暗号化の鍵を読み込む
+// create が true で鍵がない場合は新しく作成する
+func (k *Keystore) loadKey(create bool) ([]byte, error) {
+	protected, err := os.ReadFile(k.keyPath)
+	if os.IsNotExist(err) && create {
+		key := make([]byte, keySize)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		protected, err := protectKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to protect keystore key: %w", err)
+		}
+		if err := writeFile(k.keyPath, protected); err != nil {
+			return nil, err
+		}
+		return key, nil
+	}
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("keystore key %s not found; the keystore cannot be decrypted", k.keyPath)
+	}
+	if err != nil {
+		return nil, err
+	}
+	key, err := unprotectKey(protected)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unprotect keystore key %s: %w", k.keyPath, err)
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("keystore key %s is corrupted", k.keyPath)
+	}
+	return key, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// writeFile は所有者だけが読み書きできるパーミッションで data を path に書き込む
+// 書き込み中に終了してもファイルが壊れないように、一時ファイルに書いてから置き換える
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
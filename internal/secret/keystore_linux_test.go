@@ -0,0 +1,31 @@
+package secret
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestKeystoreFilePermissions(t *testing.T) {
+	k := newTestKeystore(t)
+	if err := k.Set("gyazo", "token"); err != nil {
+		t.Fatal(err)
+	}
+	// Linux では鍵をそのまま保存するので、所有者だけが読めるようにする
+	for path, want := range map[string]os.FileMode{
+		k.keyPath:               0600,
+		k.path:                  0600,
+		filepath.Dir(k.keyPath): 0700,
+	} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if perm := info.Mode().Perm(); perm != want {
+			t.Errorf("%s permission = %o, want %o", path, perm, want)
+		}
+	}
+	if data, err := os.ReadFile(k.keyPath); err != nil || len(data) != keySize {
+		t.Errorf("key file has %d bytes, %v, want %d", len(data), err, keySize)
+	}
+}
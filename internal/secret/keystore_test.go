@@ -0,0 +1,151 @@
+package secret
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// newTestKeystore は t.TempDir() に保存する Keystore を返す
+func newTestKeystore(t *testing.T) *Keystore {
+	t.Helper()
+	dir := t.TempDir()
+	return NewKeystore(filepath.Join(dir, "keystore.json"), filepath.Join(dir, "state", "keystore.key"))
+}
+
+func TestKeystoreSetGetDelete(t *testing.T) {
+	k := newTestKeystore(t)
+	if _, err := k.Get("gyazo"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get from an empty keystore: err = %v, want ErrNotFound", err)
+	}
+	if err := k.Set("gyazo", "token"); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.Set("work", "work-token"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := k.Get("gyazo"); err != nil || got != "token" {
+		t.Errorf("Get = %q, %v, want %q", got, err, "token")
+	}
+	if names, err := k.Names(); err != nil || !slices.Equal(names, []string{"gyazo", "work"}) {
+		t.Errorf("Names = %q, %v", names, err)
+	}
+
+	// 値は暗号化して保存する
+	data, err := os.ReadFile(k.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "token") {
+		t.Errorf("keystore contains the plaintext value:\n%s", data)
+	}
+
+	// 上書きした値は別の Keystore からも読める
+	if err := k.Set("gyazo", "new-token"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := NewKeystore(k.path, k.keyPath).Get("gyazo"); err != nil || got != "new-token" {
+		t.Errorf("Get after overwriting = %q, %v, want %q", got, err, "new-token")
+	}
+
+	if err := k.Delete("gyazo"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := k.Get("gyazo"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete: err = %v, want ErrNotFound", err)
+	}
+	if err := k.Delete("gyazo"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete twice: err = %v, want ErrNotFound", err)
+	}
+	if got, err := k.Get("work"); err != nil || got != "work-token" {
+		t.Errorf("Get of the remaining value = %q, %v", got, err)
+	}
+}
+
+func TestKeystoreSetEmptyName(t *testing.T) {
+	k := newTestKeystore(t)
+	if err := k.Set("", "token"); err == nil {
+		t.Error("Set with an empty name succeeded")
+	}
+}
+
+func TestKeystoreNameIsAuthenticated(t *testing.T) {
+	k := newTestKeystore(t)
+	if err := k.Set("gyazo", "token"); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.Set("work", "work-token"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 暗号文を別の名前の値と入れ替えても復号できない
+	f, err := k.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Secrets["gyazo"], f.Secrets["work"] = f.Secrets["work"], f.Secrets["gyazo"]
+	if err := k.save(f); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"gyazo", "work"} {
+		if got, err := k.Get(name); err == nil {
+			t.Errorf("Get(%q) of a swapped entry = %q, want an error", name, got)
+		}
+	}
+}
+
+func TestKeystoreCorrupted(t *testing.T) {
+	tests := []struct {
+		name    string
+		secrets map[string]string
+	}{
+		{name: "not base64", secrets: map[string]string{"gyazo": "!!"}},
+		{name: "short", secrets: map[string]string{"gyazo": "AAAA"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := newTestKeystore(t)
+			if err := k.Set("other", "token"); err != nil {
+				t.Fatal(err)
+			}
+			if err := k.save(&keystoreFile{Version: keystoreVersion, Secrets: tt.secrets}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := k.Get("gyazo"); err == nil || !strings.Contains(err.Error(), "corrupted") {
+				t.Errorf("Get: err = %v, want a corrupted error", err)
+			}
+		})
+	}
+}
+
+func TestKeystoreMissingKey(t *testing.T) {
+	k := newTestKeystore(t)
+	if err := k.Set("gyazo", "token"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(k.keyPath); err != nil {
+		t.Fatal(err)
+	}
+	// 鍵がない場合は Get で作り直さない
+	if _, err := k.Get("gyazo"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Get without the key: err = %v, want a key not found error", err)
+	}
+	if _, err := os.Stat(k.keyPath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Get created a new key: %v", err)
+	}
+}
+
+func TestKeystoreUnsupportedVersion(t *testing.T) {
+	k := newTestKeystore(t)
+	data, _ := json.Marshal(keystoreFile{Version: keystoreVersion + 1})
+	if err := os.WriteFile(k.Path(), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := k.Names(); err == nil || !strings.Contains(err.Error(), "unsupported keystore version") {
+		t.Errorf("Names: err = %v, want an unsupported version error", err)
+	}
+}
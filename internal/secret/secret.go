@@ -0,0 +1,113 @@
+// Package secret は設定ファイルに平文で書かずにトークンを指定するための参照を解決する
+//
+// 参照は次の形式で書く。接頭辞のない値はトークンそのものとして扱う
+//
+//	env:NAME        環境変数 NAME の値
+//	file:PATH       ファイル PATH の内容 (前後の空白と改行は取り除く)
+//	cmd:COMMAND     シェルで COMMAND を実行した標準出力 (pass show gyazo など)
+//	keystore:NAME   暗号化したキーストアに zgyazo secret set NAME で保存した値
+package secret
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// 参照の接頭辞
+const (
+	prefixEnv      = "env:"
+	prefixFile     = "file:"
+	prefixCmd      = "cmd:"
+	prefixKeystore = "keystore:"
+)
+
+// commandTimeout は cmd: のコマンドの実行を待つ時間
+// パスワードマネージャーがパスフレーズを尋ねることがあるため長めにしている
+const commandTimeout = 30 * time.Second
+
+// IsReference は value が参照かどうかを返す
+func IsReference(value string) bool {
+	for _, prefix := range []string{prefixEnv, prefixFile, prefixCmd, prefixKeystore} {
+		if strings.HasPrefix(value, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// KeystoreReference は name のキーストアの値を参照する文字列 ("keystore:NAME") を返す
+func KeystoreReference(name string) string {
+	return prefixKeystore + name
+}
+
+// KeystoreName は value がキーストアの参照の場合、その名前を返す
+func KeystoreName(value string) (string, bool) {
+	return strings.CutPrefix(value, prefixKeystore)
+}
+
+// Resolve は参照を解決して値を返す
+// 参照でない場合は value をそのまま返す
+func Resolve(value string) (string, error) {
+	var (
+		resolved string
+		err      error
+	)
+	switch {
+	case strings.HasPrefix(value, prefixEnv):
+		resolved, err = resolveEnv(strings.TrimPrefix(value, prefixEnv))
+	case strings.HasPrefix(value, prefixFile):
+		resolved, err = resolveFile(strings.TrimPrefix(value, prefixFile))
+	case strings.HasPrefix(value, prefixCmd):
+		resolved, err = resolveCommand(strings.TrimPrefix(value, prefixCmd))
+	case strings.HasPrefix(value, prefixKeystore):
+		resolved, err = DefaultKeystore().Get(strings.TrimPrefix(value, prefixKeystore))
+	default:
+		return value, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if resolved == "" {
+		return "", errors.New("resolved to an empty value")
+	}
+	return resolved, nil
+}
+
+func resolveEnv(name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return strings.TrimSpace(value), nil
+}
+
+func resolveFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// resolveCommand は command をシェルで実行し、標準出力の前後の空白を取り除いて返す
+func resolveCommand(command string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := shellCommand(ctx, command)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("command %q failed: %w: %s", command, err, msg)
+		}
+		return "", fmt.Errorf("command %q failed: %w", command, err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
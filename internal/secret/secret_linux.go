@@ -0,0 +1,21 @@
+package secret
+
+import (
+	"context"
+	"os/exec"
+)
+
+// shellCommand は command を sh で実行する Cmd を返す
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+// protectKey は鍵をそのまま返す
+// Linux では鍵のファイルのパーミッションで保護する
+func protectKey(key []byte) ([]byte, error) {
+	return key, nil
+}
+
+func unprotectKey(protected []byte) ([]byte, error) {
+	return protected, nil
+}
@@ -0,0 +1,85 @@
+package secret
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// useTempDirs は DefaultKeystore が t.TempDir() を使うように設定する
+func useTempDirs(t *testing.T) {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	t.Setenv("APPDATA", t.TempDir())
+	t.Setenv("LOCALAPPDATA", t.TempDir())
+}
+
+func TestResolve(t *testing.T) {
+	useTempDirs(t)
+	t.Setenv("ZGYAZO_TEST_TOKEN", " env-token\n")
+	t.Setenv("ZGYAZO_TEST_EMPTY", "")
+	file := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(file, []byte("file-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := DefaultKeystore().Set("gyazo", "keystore-token"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		value   string
+		want    string
+		wantErr string
+	}{
+		{value: "plain-token", want: "plain-token"},
+		{value: "env:ZGYAZO_TEST_TOKEN", want: "env-token"},
+		{value: "env:ZGYAZO_TEST_MISSING", wantErr: "is not set"},
+		{value: "env:ZGYAZO_TEST_EMPTY", wantErr: "empty value"},
+		{value: "file:" + file, want: "file-token"},
+		{value: "file:" + file + ".missing", wantErr: "token.missing"},
+		{value: "cmd:echo cmd-token", want: "cmd-token"},
+		{value: "cmd:exit 3", wantErr: "failed"},
+		{value: "keystore:gyazo", want: "keystore-token"},
+		{value: "keystore:missing", wantErr: "not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := Resolve(tt.value)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Resolve = %q, %v, want an error containing %q", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("Resolve = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReferences(t *testing.T) {
+	for value, want := range map[string]bool{
+		"env:GYAZO_TOKEN": true,
+		"file:/token":     true,
+		"cmd:pass gyazo":  true,
+		"keystore:gyazo":  true,
+		"plain-token":     false,
+		"ENV:GYAZO_TOKEN": false,
+	} {
+		if got := IsReference(value); got != want {
+			t.Errorf("IsReference(%q) = %v, want %v", value, got, want)
+		}
+	}
+	if ref := KeystoreReference("work"); ref != "keystore:work" {
+		t.Errorf("KeystoreReference = %q", ref)
+	}
+	if name, ok := KeystoreName("keystore:work"); !ok || name != "work" {
+		t.Errorf("KeystoreName = %q, %v", name, ok)
+	}
+	if _, ok := KeystoreName("env:work"); ok {
+		t.Error("KeystoreName accepted an env: reference")
+	}
+}
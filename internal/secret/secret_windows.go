@@ -0,0 +1,44 @@
+package secret
+
+import (
+	"context"
+	"os/exec"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// shellCommand は command を cmd.exe で実行する Cmd を返す
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	return exec.CommandContext(ctx, "cmd", "/C", command)
+}
+
+// protectKey は DPAPI (CryptProtectData) で鍵を暗号化する
+// 暗号化した鍵は同じ Windows ユーザーでしか復号できない
+func protectKey(key []byte) ([]byte, error) {
+	return cryptData(key, true)
+}
+
+// unprotectKey は DPAPI (CryptUnprotectData) で鍵を復号する
+func unprotectKey(protected []byte) ([]byte, error) {
+	return cryptData(protected, false)
+}
+
+func cryptData(data []byte, protect bool) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, windows.ERROR_INVALID_DATA
+	}
+	in := windows.DataBlob{Size: uint32(len(data)), Data: &data[0]}
+	var out windows.DataBlob
+	var err error
+	if protect {
+		err = windows.CryptProtectData(&in, nil, nil, 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out)
+	} else {
+		err = windows.CryptUnprotectData(&in, nil, nil, 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer windows.LocalFree(windows.Handle(unsafe.Pointer(out.Data)))
+	return append([]byte(nil), unsafe.Slice(out.Data, out.Size)...), nil
+}
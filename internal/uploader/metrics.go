@@ -0,0 +1,84 @@
+package uploader
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/zztkm/zgyazo/gyazo"
+	"github.com/zztkm/zgyazo/internal/metrics"
+)
+
+// 破棄した理由 (zgyazo_uploads_dropped_total の reason ラベル)
+const (
+	dropUploadQueueFull = "upload_queue_full"
+	dropRetryQueueFull  = "retry_queue_full"
+	dropMaxRetries      = "max_retries"
+)
+
+var (
+	filesDetected = metrics.NewCounter("zgyazo_files_detected_total",
+		"Number of files created in the watched directories.")
+	uploadsTotal = metrics.NewCounterVec("zgyazo_uploads_total",
+		"Number of upload attempts by result and HTTP status code (\"error\" if no response was received).", "result", "code")
+	uploadRetries = metrics.NewCounter("zgyazo_upload_retries_total",
+		"Number of upload retries.")
+	uploadsDropped = metrics.NewCounterVec("zgyazo_uploads_dropped_total",
+		"Number of files given up on without being uploaded.", "reason")
+	uploadedBytes = metrics.NewCounter("zgyazo_uploaded_bytes_total",
+		"Total size of successfully uploaded files in bytes.")
+	uploadDuration = metrics.NewHistogram("zgyazo_upload_duration_seconds",
+		"Time taken to upload a file, including failed attempts.",
+		[]float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60})
+
+	uploadQueueLength = metrics.NewGauge("zgyazo_upload_queue_length",
+		"Number of files waiting in the upload queue.")
+	retryQueueLength = metrics.NewGauge("zgyazo_retry_queue_length",
+		"Number of files waiting to be retried.")
+	uploadsInFlight = metrics.NewGauge("zgyazo_uploads_in_flight",
+		"Number of files being uploaded.")
+	uploadsPaused = metrics.NewGauge("zgyazo_uploads_paused",
+		"1 if uploads are paused, 0 otherwise.")
+)
+
+// registerMetrics はゲージの値を c から求めるように設定する
+func (c *Uploader) registerMetrics() {
+	uploadQueueLength.SetFunc(func() float64 {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return float64(len(c.uploadQueue) + len(c.held))
+	})
+	retryQueueLength.SetFunc(func() float64 {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return float64(len(c.retrying))
+	})
+	uploadsInFlight.SetFunc(func() float64 {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return float64(len(c.inFlight))
+	})
+	uploadsPaused.SetFunc(func() float64 {
+		if c.isPaused() {
+			return 1
+		}
+		return 0
+	})
+}
+
+// observeUpload はアップロード 1 回の結果と所要時間を記録する
+// size は成功した場合にアップロードしたファイルのサイズ
+func observeUpload(start time.Time, size int64, err error) {
+	uploadDuration.Observe(time.Since(start).Seconds())
+	if err == nil {
+		uploadsTotal.With("success", "200").Inc()
+		uploadedBytes.Add(float64(size))
+		return
+	}
+	code := "error"
+	var apiErr *gyazo.APIError
+	if errors.As(err, &apiErr) {
+		code = strconv.Itoa(apiErr.StatusCode)
+	}
+	uploadsTotal.With("failure", code).Inc()
+}
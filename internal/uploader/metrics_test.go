@@ -0,0 +1,85 @@
+package uploader_test
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/zztkm/zgyazo/internal/metrics"
+	"github.com/zztkm/zgyazo/internal/uploader"
+)
+
+// scrapeMetrics は /metrics と同じハンドラーからメトリクスを取得し、系列と値の対応を返す
+// 系列はラベルを含めた名前 (zgyazo_uploads_total{result="success",code="200"} など)
+func scrapeMetrics(t *testing.T) map[string]float64 {
+	t.Helper()
+	ts := httptest.NewServer(metrics.Handler(metrics.Default))
+	defer ts.Close()
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	series := make(map[string]float64)
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, ok := strings.Cut(line, " ")
+		v, err := strconv.ParseFloat(value, 64)
+		if !ok || err != nil {
+			t.Fatalf("invalid exposition line %q", line)
+		}
+		series[name] = v
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return series
+}
+
+func TestMetricsScrape(t *testing.T) {
+	env := startUploader(t)
+	// カウンターはパッケージ内のテストで共有しているので、増えた分を比べる
+	before := scrapeMetrics(t)
+
+	env.saveImage(t, "capture.png", "png data")
+	env.waitOpened(t)
+	env.up.Pause(uploader.PauseUser)
+	env.saveImage(t, "held1.png", "png data")
+	env.saveImage(t, "held2.png", "png data")
+	waitFor(t, "the files to be held", func() bool { return len(env.up.Status().Held) == 2 })
+
+	after := scrapeMetrics(t)
+	for name, want := range map[string]float64{
+		"zgyazo_files_detected_total":                       3,
+		`zgyazo_uploads_total{result="success",code="200"}`: 1,
+		"zgyazo_uploaded_bytes_total":                       float64(len("png data")),
+		"zgyazo_upload_duration_seconds_count":              1,
+	} {
+		if _, ok := after[name]; !ok {
+			t.Errorf("scraped metrics do not contain %s", name)
+			continue
+		}
+		if got := after[name] - before[name]; got != want {
+			t.Errorf("%s increased by %v, want %v", name, got, want)
+		}
+	}
+	// ゲージは scrape したときの状態を表す
+	for name, want := range map[string]float64{
+		"zgyazo_upload_queue_length": 2,
+		"zgyazo_retry_queue_length":  0,
+		"zgyazo_uploads_in_flight":   0,
+		"zgyazo_uploads_paused":      1,
+	} {
+		if got, ok := after[name]; !ok || got != want {
+			t.Errorf("%s = %v (found %v), want %v", name, got, ok, want)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package uploader
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// PauseReason はアップロードを一時停止している理由
+// 複数の理由で一時停止している場合は、すべての理由が解消されるまで再開しない
+type PauseReason string
+
+const (
+	// PauseUnauthorized はアクセストークンが無効・失効していることを表す
+	PauseUnauthorized PauseReason = "unauthorized"
+
+	// PauseUser はユーザーが操作して一時停止したことを表す
+	PauseUser PauseReason = "user"
+)
+
+// message は一時停止したときに通知するメッセージを返す
+func (r PauseReason) message() string {
+	switch r {
+	case PauseUnauthorized:
+		return "アクセストークンが無効です。zgyazo login などでトークンを更新してください"
+	case PauseUser:
+		return "再開するまで、作成された画像はアップロードせずに溜めておきます"
+	}
+	return string(r)
+}
+
+// Pause は reason でアップロードを一時停止する
+// 一時停止中もディレクトリの監視は続け、作成された画像は保存したキューに溜めておく
+// アップロード中のファイルはそのままアップロードされる
+func (c *Uploader) Pause(reason PauseReason) {
+	c.PauseFor(reason, 0)
+}
+
+// PauseFor は reason でアップロードを一時停止し、d が 0 より大きい場合は d が経過したら自動で再開する
+// すでに reason で一時停止している場合は、自動で再開するまでの時間だけ設定し直す
+func (c *Uploader) PauseFor(reason PauseReason, d time.Duration) {
+	c.mu.Lock()
+	already := slices.Contains(c.pauseReasons, reason)
+	if !already {
+		c.pauseReasons = append(c.pauseReasons, reason)
+		if c.resumed == nil {
+			c.resumed = make(chan struct{})
+		}
+	}
+	hadTimer := c.stopAutoResume(reason)
+	var resumeAt time.Time
+	if d > 0 {
+		resumeAt = time.Now().Add(d)
+		if c.resumeTimers == nil {
+			c.resumeTimers = make(map[PauseReason]*autoResume)
+		}
+		// タイマーの関数は c.mu をロックしてから確認するので、ここで設定し終えてから実行される
+		ar := &autoResume{at: resumeAt}
+		ar.timer = time.AfterFunc(d, func() { c.autoResume(reason, ar) })
+		c.resumeTimers[reason] = ar
+	}
+	c.mu.Unlock()
+
+	if already {
+		if d > 0 {
+			logger.Info("auto-resume scheduled", "reason", reason, "resume_at", resumeAt)
+			c.notify("アップロードを一時停止しています", resumeMessage(resumeAt))
+		} else if hadTimer {
+			logger.Info("auto-resume cancelled", "reason", reason)
+		}
+		return
+	}
+	message := reason.message()
+	if d > 0 {
+		logger.Warn("uploads paused", "reason", reason, "resume_at", resumeAt)
+		message += "\n" + resumeMessage(resumeAt)
+	} else {
+		logger.Warn("uploads paused", "reason", reason)
+	}
+	c.notify("アップロードを一時停止しました", message)
+}
+
+// autoResume は PauseFor で設定した自動で再開するタイマー
+type autoResume struct {
+	timer *time.Timer
+	at    time.Time
+}
+
+// autoResume は PauseFor で設定した時間が経過したときに呼ばれ、一時停止を解除する
+// 設定し直されたり解除されたりしたタイマーの場合は何もしない
+func (c *Uploader) autoResume(reason PauseReason, ar *autoResume) {
+	c.mu.Lock()
+	current := c.resumeTimers[reason] == ar
+	c.mu.Unlock()
+	if !current {
+		return
+	}
+	logger.Info("auto-resume timer expired", "reason", reason)
+	c.Resume(reason)
+}
+
+// stopAutoResume は reason の自動で再開するタイマーを止め、タイマーがあったかどうかを返す
+// c.mu をロックして呼び出す
+func (c *Uploader) stopAutoResume(reason PauseReason) bool {
+	ar, ok := c.resumeTimers[reason]
+	if !ok {
+		return false
+	}
+	ar.timer.Stop()
+	delete(c.resumeTimers, reason)
+	return true
+}
+
+func resumeMessage(at time.Time) string {
+	return fmt.Sprintf("%s に自動で再開します", at.Format("15:04"))
+}
+
+// Resume は reason による一時停止を解除する
+// ほかの理由で一時停止していない場合はアップロードを再開する
+func (c *Uploader) Resume(reason PauseReason) {
+	c.mu.Lock()
+	i := slices.Index(c.pauseReasons, reason)
+	if i < 0 {
+		c.mu.Unlock()
+		return
+	}
+	c.pauseReasons = slices.Delete(c.pauseReasons, i, i+1)
+	c.stopAutoResume(reason)
+	remaining := len(c.pauseReasons)
+	if remaining == 0 {
+		close(c.resumed)
+		c.resumed = nil
+	}
+	held := len(c.held)
+	c.mu.Unlock()
+
+	if remaining > 0 {
+		logger.Info("pause reason resolved, uploads are still paused", "reason", reason)
+		return
+	}
+	logger.Info("uploads resumed", "reason", reason, "held", held)
+	go c.releaseHeld()
+	if held > 0 {
+		c.notify("アップロードを再開しました", fmt.Sprintf("溜まっている %d 件の画像をアップロードします", held))
+	} else {
+		c.notify("アップロードを再開しました", "溜まっている画像をアップロードします")
+	}
+}
+
+// releaseHeld は一時停止中に溜めておいた画像を順にアップロードのキューに追加する
+// キューがいっぱいの場合は空くまで待つ。もう一度一時停止した場合や停止した場合は、残りを溜めたままにする
+func (c *Uploader) releaseHeld() {
+	for {
+		c.mu.Lock()
+		if c.stopped || len(c.pauseReasons) > 0 || len(c.held) == 0 {
+			c.mu.Unlock()
+			return
+		}
+		select {
+		case c.uploadQueue <- c.held[0]:
+			c.held = c.held[1:]
+			c.mu.Unlock()
+			continue
+		default:
+		}
+		c.mu.Unlock()
+
+		select {
+		case <-c.stopCh:
+			return
+		case <-time.After(releaseRetryDelay):
+		}
+	}
+}
+
+// IsPaused は reason でアップロードを一時停止しているかどうかを返す
+func (c *Uploader) IsPaused(reason PauseReason) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Contains(c.pauseReasons, reason)
+}
+
+// isPaused は理由にかかわらず一時停止しているかどうかを返す
+func (c *Uploader) isPaused() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.pauseReasons) > 0
+}
+
+// waitResumed は一時停止している場合、再開されるまで待つ
+// 待っている間に Uploader かワーカーが停止した場合は false を返す
+func (c *Uploader) waitResumed(quit <-chan struct{}) bool {
+	c.mu.Lock()
+	resumed := c.resumed
+	c.mu.Unlock()
+	if resumed == nil {
+		return true
+	}
+	select {
+	case <-resumed:
+		return true
+	case <-c.stopCh:
+		return false
+	case <-quit:
+		return false
+	}
+}
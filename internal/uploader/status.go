@@ -0,0 +1,229 @@
+package uploader
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+)
+
+// retryWorkerID は InFlight.Worker でリトライ用のワーカーを表す
+const retryWorkerID = -1
+
+// ErrQueueFull はアップロードのキューがいっぱいで追加できないことを表す
+var ErrQueueFull = errors.New("upload queue is full")
+
+// Status は Uploader の現在の状態
+type Status struct {
+	// QueueLength はアップロードを待っている画像の数
+	QueueLength int `json:"queue_length"`
+
+	// InFlight はアップロード中の画像
+	InFlight []InFlight `json:"in_flight"`
+
+	// Waiting は一時停止中にワーカーが取り出し、再開を待っている画像
+	Waiting []string `json:"waiting"`
+
+	// Held は一時停止中に作成され、再開したらキューに追加する画像
+	Held []string `json:"held"`
+
+	// Retries はリトライを待っている画像
+	Retries []Retry `json:"retries"`
+
+	// PauseReasons はアップロードを一時停止している理由 (一時停止していない場合は空)
+	PauseReasons []PauseReason `json:"pause_reasons"`
+
+	// AutoResumeAt は一時停止の理由ごとの自動で再開する時刻
+	AutoResumeAt map[PauseReason]time.Time `json:"auto_resume_at,omitempty"`
+
+	Workers int `json:"workers"`
+
+	Watches []WatchStatus `json:"watches"`
+
+	// WatcherError はファイルの監視で最後に起きたエラー (起きていない場合は空)
+	WatcherError   string     `json:"watcher_error,omitempty"`
+	WatcherErrorAt *time.Time `json:"watcher_error_at,omitempty"`
+}
+
+// InFlight はアップロード中の画像 1 件
+type InFlight struct {
+	FilePath string `json:"file_path"`
+
+	// Worker はアップロードしているワーカーの番号 (リトライの場合は -1)
+	Worker int `json:"worker"`
+
+	// Attempt は何回目のアップロードか
+	Attempt int `json:"attempt"`
+
+	StartedAt time.Time `json:"started_at"`
+}
+
+// Retry はリトライを待っている画像 1 件
+type Retry struct {
+	FilePath string `json:"file_path"`
+
+	// Attempts はこれまでにアップロードを試みた回数
+	Attempts int `json:"attempts"`
+
+	LastAttempt time.Time `json:"last_attempt"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// WatchStatus は監視しているディレクトリ 1 つの状態
+type WatchStatus struct {
+	Path string `json:"path"`
+
+	// Watching はファイルの監視に登録しているかどうか
+	Watching bool `json:"watching"`
+
+	// Error はディレクトリにアクセスできない場合の理由
+	Error string `json:"error,omitempty"`
+}
+
+// Status は現在の状態を返す
+func (c *Uploader) Status() Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := Status{
+		QueueLength:  len(c.uploadQueue),
+		PauseReasons: slices.Clone(c.pauseReasons),
+		Held:         slices.Clone(c.held),
+		Retries:      slices.Clone(c.retrying),
+		Workers:      c.workerCount,
+		WatcherError: c.watcherErr,
+	}
+	if c.watcherErr != "" {
+		at := c.watcherErrAt
+		s.WatcherErrorAt = &at
+	}
+	for reason, ar := range c.resumeTimers {
+		if s.AutoResumeAt == nil {
+			s.AutoResumeAt = make(map[PauseReason]time.Time)
+		}
+		s.AutoResumeAt[reason] = ar.at
+	}
+	for f := range c.waiting {
+		s.Waiting = append(s.Waiting, f)
+	}
+	slices.Sort(s.Waiting)
+	for _, f := range c.inFlight {
+		s.InFlight = append(s.InFlight, f)
+	}
+	slices.SortFunc(s.InFlight, func(a, b InFlight) int {
+		return a.StartedAt.Compare(b.StartedAt)
+	})
+
+	var watching []string
+	if c.watcher != nil {
+		watching = c.watcher.WatchList()
+	}
+	for _, path := range watchPaths(c.watches) {
+		w := WatchStatus{Path: path, Watching: slices.Contains(watching, path)}
+		if info, err := os.Stat(path); err != nil {
+			w.Error = err.Error()
+		} else if !info.IsDir() {
+			w.Error = "not a directory"
+		}
+		s.Watches = append(s.Watches, w)
+	}
+	return s
+}
+
+// Enqueue は filePath の画像をアップロードのキューに追加する
+// 一時停止中は再開するまで溜めておく
+// 監視ディレクトリの外のファイルは、SetAccount で設定したアカウントでアップロードする
+func (c *Uploader) Enqueue(filePath string) error {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", filePath)
+	}
+
+	held, err := c.submit(filePath)
+	if err != nil {
+		return err
+	}
+	if held {
+		logger.Info("held upload while paused", "file", filePath)
+	} else {
+		logger.Info("queued upload", "file", filePath)
+	}
+	return nil
+}
+
+// RetryDeadLetters はリトライしてもアップロードできなかった画像をキューに追加し直し、追加した件数を返す
+// キューに追加した画像は記録から削除する。もう一度失敗した場合は改めて記録される
+func (c *Uploader) RetryDeadLetters() (int, error) {
+	n := 0
+	var errs []error
+	for _, entry := range c.deadLetters.Entries() {
+		if err := c.Enqueue(entry.FilePath); err != nil {
+			if errors.Is(err, ErrQueueFull) {
+				return n, err
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", entry.FilePath, err))
+			continue
+		}
+		if err := c.deadLetters.Remove(entry.FilePath); err != nil {
+			errs = append(errs, err)
+		}
+		n++
+	}
+	return n, errors.Join(errs...)
+}
+
+// startInFlight は filePath のアップロードを始めたことを記録し、終わったときに呼び出す関数を返す
+func (c *Uploader) startInFlight(filePath string, worker, attempt int) func() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.inFlight == nil {
+		c.inFlight = make(map[string]InFlight)
+	}
+	c.inFlight[filePath] = InFlight{FilePath: filePath, Worker: worker, Attempt: attempt, StartedAt: time.Now()}
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		delete(c.inFlight, filePath)
+	}
+}
+
+// startWaiting は filePath が再開を待っていることを記録し、待ち終わったときに呼び出す関数を返す
+func (c *Uploader) startWaiting(filePath string) func() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.pauseReasons) == 0 {
+		return func() {}
+	}
+	if c.waiting == nil {
+		c.waiting = make(map[string]struct{})
+	}
+	c.waiting[filePath] = struct{}{}
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		delete(c.waiting, filePath)
+	}
+}
+
+// setRetrying はリトライを待っている画像を Status で返せるように記録する
+func (c *Uploader) setRetrying(items []retryItem) {
+	retrying := make([]Retry, len(items))
+	for i, item := range items {
+		retrying[i] = Retry{FilePath: item.filePath, Attempts: item.retryCount, LastAttempt: item.lastAttempt, LastError: item.lastError}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.retrying = retrying
+}
+
+// setWatcherError はファイルの監視で起きたエラーを Status で返せるように記録する
+func (c *Uploader) setWatcherError(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.watcherErr = err.Error()
+	c.watcherErrAt = time.Now()
+}
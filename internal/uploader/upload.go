@@ -0,0 +1,54 @@
+package uploader
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/zztkm/zgyazo/gyazo"
+	"github.com/zztkm/zgyazo/internal/history"
+)
+
+// DefaultUploadOptions は監視ディレクトリの画像をアップロードするときのオプションを返す
+// 公開範囲は設定ファイルの access_policy で変更される
+func DefaultUploadOptions() *gyazo.UploadOptions {
+	return &gyazo.UploadOptions{
+		AccessPolicy:     gyazo.AccessPolicyAnyone,
+		MetadataIsPublic: gyazo.Bool(false),
+	}
+}
+
+// UploadFile は filePath の画像を account で Gyazo にアップロードし、hist に記録する
+// ファイルが他のプロセスによって使用されている場合はリトライする
+func UploadFile(ctx context.Context, account *Account, hist *history.Store, filePath string) (*gyazo.UploadResponse, error) {
+	file, err := openFileWithRetry(filePath, 5, 200*time.Millisecond)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Upload(ctx, account, hist, filePath, file)
+}
+
+// Upload は r から読み込んだ画像を name として account で Gyazo にアップロードし、hist に記録する
+// 履歴にはあとで同じアカウントで削除できるように account のプロファイルも記録する
+func Upload(ctx context.Context, account *Account, hist *history.Store, name string, r io.Reader) (*gyazo.UploadResponse, error) {
+	uploadResp, err := account.Client.Upload(ctx, name, r, account.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Debug("upload successful", "file", name, "image_id", uploadResp.ImageID, "url", uploadResp.PermalinkURL)
+	// 削除や取り消しに使うため履歴に残す。履歴の保存に失敗してもアップロードは成功として扱う
+	err = hist.Add(history.Entry{
+		ImageID:      uploadResp.ImageID,
+		PermalinkURL: uploadResp.PermalinkURL,
+		URL:          uploadResp.URL,
+		FilePath:     name,
+		UploadedAt:   time.Now(),
+		Profile:      account.Profile,
+	})
+	if err != nil {
+		logger.Error("failed to save history", "file", name, "image_id", uploadResp.ImageID, "error", err)
+	}
+	return uploadResp, nil
+}
@@ -0,0 +1,396 @@
+// Package uploader はディレクトリを監視し、作成された画像を Gyazo にアップロードする
+package uploader
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/zztkm/zgyazo/gyazo"
+	"github.com/zztkm/zgyazo/internal/deadletter"
+	"github.com/zztkm/zgyazo/internal/history"
+	"github.com/zztkm/zgyazo/internal/logging"
+	"github.com/zztkm/zgyazo/internal/platform"
+	"github.com/zztkm/zgyazo/internal/queue"
+)
+
+var logger = logging.Logger(logging.ComponentUploader)
+
+const (
+	defaultWorkerCount = 3
+	uploadQueueSize    = 100
+	retryQueueSize     = 50
+	maxRetryCount      = 3
+	retryDelay         = 5 * time.Second
+
+	// releaseRetryDelay は再開後に溜めておいた画像を追加するとき、キューが空くのを待つ間隔
+	releaseRetryDelay = 100 * time.Millisecond
+)
+
+// retryItem represents an upload that needs to be retried
+type retryItem struct {
+	filePath    string
+	retryCount  int
+	lastAttempt time.Time
+	lastError   string
+}
+
+// Account はアップロードに使う Gyazo API クライアントとアップロードのオプション
+type Account struct {
+	// Profile は設定ファイルのプロファイルの名前 (ログに表示する)
+	Profile string
+
+	Client  *gyazo.Client
+	Options *gyazo.UploadOptions
+}
+
+// Watch は監視するディレクトリと、そのディレクトリの画像のアップロード先
+type Watch struct {
+	Path string
+
+	// Account が nil の場合は SetAccount で設定したアカウントでアップロードする
+	Account *Account
+}
+
+// Uploader は監視ディレクトリに作成された画像を Gyazo にアップロードする
+type Uploader struct {
+	// 監視ディレクトリにアカウントが指定されていない場合に使うアカウント
+	// 設定の再読み込みやプロファイルの切り替えで差し替えられる
+	account atomic.Pointer[Account]
+
+	// アップロードした URL を開く・結果を通知する
+	opener   platform.URLOpener
+	notifier platform.Notifier
+
+	// アップロードした画像の履歴
+	history *history.Store
+
+	// リトライしてもアップロードできなかった画像
+	deadLetters *deadletter.Store
+
+	// アップロードし終えていない画像。終了しても次の起動時にアップロードし直す
+	pending *queue.Store
+
+	// mu は以下の監視とワーカーの状態を保護する
+	mu sync.Mutex
+
+	// 監視するディレクトリ (Snipping Tool が画像を保存するパスなど)
+	watches []Watch
+	// watcher は Run の実行中だけ設定される
+	watcher *fsnotify.Watcher
+
+	// Concurrent upload handling
+	uploadQueue chan string
+	workerCount int
+	// workerStops はワーカーごとの停止用チャネル。ワーカー数を減らすときに末尾から閉じる
+	workerStops []chan struct{}
+	running     bool
+	stopped     bool
+	stopCh      chan struct{}
+	wg          sync.WaitGroup
+
+	// pauseReasons はアップロードを一時停止している理由
+	// resumed は一時停止中だけ設定され、再開すると閉じられる
+	pauseReasons []PauseReason
+	resumed      chan struct{}
+	// held は一時停止中に作成された画像で、再開するとキューに追加する
+	held []string
+	// resumeTimers は自動で再開するタイマー
+	resumeTimers map[PauseReason]*autoResume
+
+	// Retry handling
+	retryQueue chan retryItem
+	retryWg    sync.WaitGroup
+
+	// Status で返す状態
+	// inFlight はアップロード中の画像、waiting は一時停止中に再開を待っている画像、
+	// retrying はリトライ用のワーカーが待たせている画像
+	inFlight     map[string]InFlight
+	waiting      map[string]struct{}
+	retrying     []Retry
+	watcherErr   string
+	watcherErrAt time.Time
+}
+
+// New は watches のディレクトリに作成された画像を account でアップロードする Uploader を生成します。
+// アップロードした画像は hist に、リトライしてもアップロードできなかった画像は dead に記録します。
+// アップロードし終えていない画像は pending に保存し、次に Run を実行したときにアップロードし直します。
+func New(account *Account, watches []Watch, opener platform.URLOpener, notifier platform.Notifier, hist *history.Store, dead *deadletter.Store, pending *queue.Store) *Uploader {
+	c := &Uploader{
+		watches:     slices.Clone(watches),
+		opener:      opener,
+		notifier:    notifier,
+		history:     hist,
+		deadLetters: dead,
+		pending:     pending,
+		uploadQueue: make(chan string, uploadQueueSize),
+		workerCount: defaultWorkerCount,
+		stopCh:      make(chan struct{}),
+		retryQueue:  make(chan retryItem, retryQueueSize),
+	}
+	c.account.Store(account)
+	return c
+}
+
+// SetAccount は以降のアップロードに使うアカウントを差し替える
+// アップロード中のファイルは差し替え前のアカウントでアップロードされる
+func (c *Uploader) SetAccount(account *Account) {
+	c.account.Store(account)
+}
+
+// SetWatches は監視するディレクトリを watches に変更する
+// 追加に失敗した場合は監視を変更せずにエラーを返す
+func (c *Uploader) SetWatches(watches []Watch) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	paths := watchPaths(watches)
+	if c.watcher != nil {
+		current := watchPaths(c.watches)
+		// 元の監視に戻せるように、追加をすべて済ませてから削除する
+		var added []string
+		for _, path := range paths {
+			if slices.Contains(current, path) {
+				continue
+			}
+			if err := c.watcher.Add(path); err != nil {
+				for _, p := range added {
+					c.watcher.Remove(p)
+				}
+				return err
+			}
+			added = append(added, path)
+			logger.Info("started watching", "dir", path)
+		}
+		for _, path := range current {
+			if slices.Contains(paths, path) {
+				continue
+			}
+			if err := c.watcher.Remove(path); err != nil {
+				logger.Warn("failed to stop watching", "dir", path, "error", err)
+				continue
+			}
+			logger.Info("stopped watching", "dir", path)
+		}
+	}
+	c.watches = slices.Clone(watches)
+	return nil
+}
+
+// Accounts はアップロードに使うアカウントをすべて返す
+// 最初の要素は SetAccount で設定したアカウントで、そのあとに監視ディレクトリごとのアカウントが続く
+func (c *Uploader) Accounts() []*Account {
+	accounts := []*Account{c.account.Load()}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, w := range c.watches {
+		if w.Account != nil && !slices.Contains(accounts, w.Account) {
+			accounts = append(accounts, w.Account)
+		}
+	}
+	return accounts
+}
+
+// accountFor は filePath の画像のアップロードに使うアカウントを返す
+func (c *Uploader) accountFor(filePath string) *Account {
+	dir := filepath.Clean(filepath.Dir(filePath))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, w := range c.watches {
+		if w.Account != nil && filepath.Clean(w.Path) == dir {
+			return w.Account
+		}
+	}
+	return c.account.Load()
+}
+
+func watchPaths(watches []Watch) []string {
+	paths := make([]string, len(watches))
+	for i, w := range watches {
+		paths[i] = w.Path
+	}
+	return paths
+}
+
+// SetWorkerCount はアップロードするワーカーの数を n に変更する
+// 減らす場合、アップロード中のワーカーはそのファイルを処理し終えてから停止する
+func (c *Uploader) SetWorkerCount(n int) {
+	if n < 1 {
+		n = defaultWorkerCount
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.workerCount = n
+	if c.running && !c.stopped {
+		c.resizeWorkers()
+	}
+}
+
+// Run は Uploader を実行します
+// このメソッドは設定されたディレクトリを監視し続けるため
+// 非同期で実行する必要があります
+func (c *Uploader) Run() error {
+	// Start upload workers
+	c.mu.Lock()
+	c.running = true
+	c.resizeWorkers()
+	c.mu.Unlock()
+	c.registerMetrics()
+
+	// Start retry worker
+	c.startRetryWorker()
+
+	// 前回の実行でアップロードし終えなかった画像
+	c.restorePending()
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	c.mu.Lock()
+	for _, path := range watchPaths(c.watches) {
+		if err := watcher.Add(path); err != nil {
+			c.mu.Unlock()
+			return fmt.Errorf("failed to watch %s: %w", path, err)
+		}
+		logger.Info("started watching", "dir", path)
+	}
+	c.watcher = watcher
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.watcher = nil
+		c.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			logger.Debug("file event", "file", event.Name, "op", event.Op.String())
+
+			// ファイルが作成された場合にGyazoアップロードサービスを実行
+			if event.Op&fsnotify.Create == fsnotify.Create {
+				filesDetected.Inc()
+				// Queue the upload instead of blocking
+				held, err := c.submit(event.Name)
+				switch {
+				case errors.Is(err, ErrQueueFull):
+					logger.Warn("upload queue full, dropping", "file", event.Name)
+					uploadsDropped.With(dropUploadQueueFull).Inc()
+				case err != nil:
+					logger.Debug("file created after stop, ignored", "file", event.Name)
+				case held:
+					logger.Info("file created, held while paused", "file", event.Name)
+				default:
+					logger.Info("file created, queued upload", "file", event.Name)
+				}
+			}
+		case <-c.stopCh:
+			logger.Debug("file watch loop stopped")
+			return nil
+		case err := <-watcher.Errors:
+			logger.Error("file watcher error", "error", err)
+			c.setWatcherError(err)
+		}
+	}
+}
+
+// errUploaderStopped は Stop のあとに画像を追加しようとしたことを表す
+var errUploaderStopped = errors.New("uploader is stopped")
+
+// submit は filePath をアップロードし終えていない画像として保存し、アップロードのキューに追加する
+// 一時停止中はキューに追加せずに溜めておき、溜めておいた場合は true を返す
+// 一時停止中に溜めておく画像は、保存したファイルと同じく件数の上限を設けない
+func (c *Uploader) submit(filePath string) (bool, error) {
+	// ファイルへの書き込みでほかの処理を待たせないよう、c.mu の外で保存する
+	// キューに追加したあとに保存すると、ワーカーがアップロードし終えて削除したあとに保存してしまうため、先に保存する
+	c.savePending(filePath)
+	c.mu.Lock()
+	held, err := c.submitLocked(filePath)
+	c.mu.Unlock()
+	if err != nil {
+		// 追加できなかった画像は、次の起動時にもアップロードしない
+		c.forget(filePath)
+	}
+	return held, err
+}
+
+// submitLocked は filePath を溜めておくか、アップロードのキューに追加する
+// c.mu をロックして呼び出す
+func (c *Uploader) submitLocked(filePath string) (bool, error) {
+	// Stop のあとはワーカーがキューを読まないため、追加しない
+	if c.stopped {
+		return false, errUploaderStopped
+	}
+	if len(c.pauseReasons) > 0 {
+		if !slices.Contains(c.held, filePath) {
+			c.held = append(c.held, filePath)
+		}
+		return true, nil
+	}
+	select {
+	case c.uploadQueue <- filePath:
+		return false, nil
+	default:
+		return false, ErrQueueFull
+	}
+}
+
+// savePending は filePath をアップロードし終えていない画像として保存する
+// 保存に失敗してもアップロードは続ける
+func (c *Uploader) savePending(filePath string) {
+	if err := c.pending.Add(filePath); err != nil {
+		logger.Error("failed to save queue", "file", filePath, "error", err)
+	}
+}
+
+// forget は filePath をアップロードし終えていない画像から削除する
+// アップロードに成功した場合や、諦めた場合に呼び出す
+func (c *Uploader) forget(filePath string) {
+	if err := c.pending.Remove(filePath); err != nil {
+		logger.Error("failed to save queue", "file", filePath, "error", err)
+	}
+}
+
+// restorePending は前回の実行でアップロードし終えなかった画像を溜めておき、
+// 一時停止していなければ順にキューに追加する
+// 削除されたファイルは保存した画像からも削除する
+func (c *Uploader) restorePending() {
+	entries := c.pending.Entries()
+	if len(entries) == 0 {
+		return
+	}
+	var restored []string
+	for _, e := range entries {
+		if _, err := os.Stat(e.FilePath); err != nil {
+			logger.Warn("queued file is no longer available, skipping", "file", e.FilePath, "error", err)
+			c.forget(e.FilePath)
+			continue
+		}
+		restored = append(restored, e.FilePath)
+	}
+
+	c.mu.Lock()
+	for _, filePath := range restored {
+		if !slices.Contains(c.held, filePath) {
+			c.held = append(c.held, filePath)
+		}
+	}
+	paused := len(c.pauseReasons) > 0
+	c.mu.Unlock()
+	logger.Info("restored queued uploads from previous run", "count", len(restored), "paused", paused)
+	if !paused {
+		go c.releaseHeld()
+	}
+}
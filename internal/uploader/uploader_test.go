@@ -0,0 +1,224 @@
+package uploader_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zztkm/zgyazo/gyazo/gyazotest"
+	"github.com/zztkm/zgyazo/internal/deadletter"
+	"github.com/zztkm/zgyazo/internal/history"
+	"github.com/zztkm/zgyazo/internal/platform/platformtest"
+	"github.com/zztkm/zgyazo/internal/queue"
+	"github.com/zztkm/zgyazo/internal/uploader"
+)
+
+// waitTimeout はアップロードなどの非同期の処理を待つ時間
+const waitTimeout = 5 * time.Second
+
+type testEnv struct {
+	srv      *gyazotest.Server
+	up       *uploader.Uploader
+	opener   *platformtest.URLOpener
+	notifier *platformtest.Notifier
+	history  *history.Store
+	pending  *queue.Store
+	watchDir string
+}
+
+// startUploader は fake サーバーにアップロードする Uploader を t.TempDir() の監視ディレクトリで起動する
+func startUploader(t *testing.T) *testEnv {
+	t.Helper()
+	srv := gyazotest.NewServer("test-token")
+	t.Cleanup(srv.Close)
+	client, err := srv.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state := t.TempDir()
+	hist, err := history.Open(filepath.Join(state, "history.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dead, err := deadletter.Open(filepath.Join(state, "deadletter.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending, err := queue.Open(filepath.Join(state, "queue.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env := &testEnv{
+		srv:      srv,
+		opener:   platformtest.NewURLOpener(),
+		notifier: &platformtest.Notifier{},
+		history:  hist,
+		pending:  pending,
+		watchDir: t.TempDir(),
+	}
+	account := &uploader.Account{Client: client, Options: uploader.DefaultUploadOptions()}
+	env.up = uploader.New(account, []uploader.Watch{{Path: env.watchDir}}, env.opener, env.notifier, hist, dead, pending)
+
+	done := make(chan error, 1)
+	go func() { done <- env.up.Run() }()
+	t.Cleanup(func() {
+		env.up.Stop()
+		if err := <-done; err != nil {
+			t.Errorf("Run: %v", err)
+		}
+	})
+
+	waitFor(t, "watching the directory", func() bool {
+		watches := env.up.Status().Watches
+		return len(watches) == 1 && watches[0].Watching
+	})
+	return env
+}
+
+// saveImage はキャプチャツールが画像を保存したときと同じように、書き終えたファイルを監視ディレクトリに置く
+func (env *testEnv) saveImage(t *testing.T, name, data string) string {
+	t.Helper()
+	tmp := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(tmp, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(env.watchDir, name)
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(waitTimeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (env *testEnv) waitOpened(t *testing.T) string {
+	t.Helper()
+	select {
+	case url := <-env.opener.Opened():
+		return url
+	case <-time.After(waitTimeout):
+		t.Fatal("timed out waiting for the uploaded URL to be opened")
+		return ""
+	}
+}
+
+func TestDetectUploadOpen(t *testing.T) {
+	env := startUploader(t)
+	path := env.saveImage(t, "capture.png", "png data")
+
+	url := env.waitOpened(t)
+
+	images := env.srv.Images()
+	if len(images) != 1 {
+		t.Fatalf("server has %d images, want 1", len(images))
+	}
+	if url != images[0].PermalinkURL {
+		t.Errorf("opened %q, want the permalink %q", url, images[0].PermalinkURL)
+	}
+	if data, _ := env.srv.ImageData(images[0].ImageID); string(data) != "png data" {
+		t.Errorf("uploaded data = %q, want %q", data, "png data")
+	}
+	last, ok := env.history.Last()
+	if !ok || last.FilePath != path || last.PermalinkURL != url {
+		t.Errorf("history last = %+v (found %v), want %s uploaded as %s", last, ok, path, url)
+	}
+	waitFor(t, "the saved queue to be emptied", func() bool { return env.pending.Len() == 0 })
+}
+
+func TestPauseHoldsFilesUntilResumed(t *testing.T) {
+	env := startUploader(t)
+	env.up.Pause(uploader.PauseUser)
+	path := env.saveImage(t, "held.png", "png data")
+
+	waitFor(t, "the file to be held", func() bool { return len(env.up.Status().Held) == 1 })
+	if entries := env.pending.Entries(); len(entries) != 1 || entries[0].FilePath != path {
+		t.Errorf("saved queue = %+v, want %s", entries, path)
+	}
+	if n := len(env.srv.Images()); n != 0 {
+		t.Fatalf("uploaded %d images while paused", n)
+	}
+
+	env.up.Resume(uploader.PauseUser)
+	url := env.waitOpened(t)
+	if images := env.srv.Images(); len(images) != 1 || images[0].PermalinkURL != url {
+		t.Errorf("server images = %+v, opened %q", images, url)
+	}
+	waitFor(t, "the saved queue to be emptied", func() bool { return env.pending.Len() == 0 })
+}
+
+func TestPauseHoldsFilesBeyondQueueSize(t *testing.T) {
+	env := startUploader(t)
+	env.up.Pause(uploader.PauseUser)
+
+	// アップロードのキューに入りきらない件数でも、一時停止中は溜めておいて保存する
+	const n = 1001
+	dir := t.TempDir()
+	for i := range n {
+		file := filepath.Join(dir, fmt.Sprintf("capture%d.png", i))
+		if err := os.WriteFile(file, []byte("png data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := env.up.Enqueue(file); err != nil {
+			t.Fatalf("Enqueue(%s) while paused: %v", file, err)
+		}
+	}
+	if held := len(env.up.Status().Held); held != n {
+		t.Errorf("held %d files, want %d", held, n)
+	}
+	if saved := env.pending.Len(); saved != n {
+		t.Errorf("saved %d files, want %d", saved, n)
+	}
+}
+
+func TestEnqueueWhileStopping(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "api.png")
+	if err := os.WriteFile(file, []byte("png data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	var env *testEnv
+	// startUploader の後始末で Stop したあとに実行される
+	t.Cleanup(func() {
+		close(done)
+		wg.Wait()
+		if err := env.up.Enqueue(file); err == nil || errors.Is(err, uploader.ErrQueueFull) {
+			t.Errorf("Enqueue after Stop: err = %v, want the uploader to be stopped", err)
+		}
+	})
+	env = startUploader(t)
+	// アップロードを失敗させ、ワーカーがリトライのキューにも送り続けるようにする
+	env.srv.Close()
+
+	// API のハンドラーと同じように、Stop の最中も別の goroutine から追加し続ける
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					env.up.Enqueue(file)
+				}
+			}
+		}()
+	}
+	waitFor(t, "a failed upload to be retried", func() bool { return len(env.up.Status().Retries) > 0 })
+}
@@ -0,0 +1,247 @@
+package uploader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/zztkm/zgyazo/gyazo"
+	"github.com/zztkm/zgyazo/internal/deadletter"
+)
+
+// uploadImage は指定されたファイルパスの画像を Gyazo にアップロードし、画像の URL を返す
+// worker と attempt はアップロード中の画像として Status に表示する
+func (c *Uploader) uploadImage(filePath string, worker, attempt int) (string, error) {
+	defer c.startInFlight(filePath, worker, attempt)()
+	account := c.accountFor(filePath)
+	logger.Debug("uploading", "file", filePath, "profile", account.Profile)
+	var size int64
+	if info, err := os.Stat(filePath); err == nil {
+		size = info.Size()
+	}
+	start := time.Now()
+	uploadResp, err := UploadFile(context.Background(), account, c.history, filePath)
+	observeUpload(start, size, err)
+	if err != nil {
+		return "", err
+	}
+	// open 用の url を返す
+	// TODO: config で開く URL を設定可能にする
+	return uploadResp.PermalinkURL, nil
+}
+
+// errStopped は一時停止中に Uploader かワーカーが停止したことを表す
+var errStopped = errors.New("uploader stopped")
+
+// uploadUnlessPaused は一時停止している場合は再開を待ってから filePath の画像をアップロードする
+// トークンが無効なエラーになった場合は、リトライ回数を消費しないよう一時停止して再開後にやり直す
+func (c *Uploader) uploadUnlessPaused(filePath string, worker int, quit <-chan struct{}) (string, error) {
+	for {
+		done := c.startWaiting(filePath)
+		resumed := c.waitResumed(quit)
+		done()
+		if !resumed {
+			return "", errStopped
+		}
+		url, err := c.uploadImage(filePath, worker, 1)
+		if !gyazo.IsUnauthorized(err) {
+			return url, err
+		}
+		logger.Error("access token was rejected while uploading", "file", filePath, "error", err)
+		c.Pause(PauseUnauthorized)
+	}
+}
+
+// resizeWorkers は実行中のワーカーの数を workerCount に合わせる
+// c.mu をロックして呼び出す
+func (c *Uploader) resizeWorkers() {
+	for len(c.workerStops) < c.workerCount {
+		quit := make(chan struct{})
+		c.workerStops = append(c.workerStops, quit)
+		c.wg.Add(1)
+		go c.uploadWorker(len(c.workerStops)-1, quit)
+	}
+	for len(c.workerStops) > c.workerCount {
+		last := len(c.workerStops) - 1
+		close(c.workerStops[last])
+		c.workerStops = c.workerStops[:last]
+	}
+}
+
+// uploadWorker processes uploads from the queue
+func (c *Uploader) uploadWorker(id int, quit <-chan struct{}) {
+	defer c.wg.Done()
+	logger := logger.With("worker", id)
+	logger.Debug("upload worker started")
+
+	for {
+		select {
+		case filePath := <-c.uploadQueue:
+			logger.Info("processing", "file", filePath)
+			url, err := c.uploadUnlessPaused(filePath, id, quit)
+			if errors.Is(err, errStopped) {
+				logger.Info("upload worker stopping while paused, not uploaded", "file", filePath)
+				return
+			}
+			if err != nil {
+				logger.Error("failed to upload", "file", filePath, "attempt", 1, "error", err)
+				// Add to retry queue
+				select {
+				case c.retryQueue <- retryItem{filePath: filePath, retryCount: 1, lastAttempt: time.Now(), lastError: err.Error()}:
+					logger.Info("added to retry queue", "file", filePath)
+				default:
+					logger.Warn("retry queue full, dropping", "file", filePath)
+					uploadsDropped.With(dropRetryQueueFull).Inc()
+					c.forget(filePath)
+				}
+			} else {
+				logger.Info("uploaded", "file", filePath, "url", url)
+				c.forget(filePath)
+				c.notify("アップロードしました", url)
+				if err := c.opener.Open(url); err != nil {
+					logger.Error("failed to open URL", "url", url, "error", err)
+				}
+			}
+		case <-c.stopCh:
+			logger.Debug("upload worker stopping")
+			return
+		case <-quit:
+			logger.Info("upload worker stopping (worker count reduced)")
+			return
+		}
+	}
+}
+
+// startRetryWorker starts a goroutine that retries failed uploads
+func (c *Uploader) startRetryWorker() {
+	c.retryWg.Add(1)
+	go func() {
+		defer c.retryWg.Done()
+		ticker := time.NewTicker(retryDelay)
+		defer ticker.Stop()
+
+		pendingRetries := make([]retryItem, 0, retryQueueSize)
+
+		for {
+			select {
+			case item := <-c.retryQueue:
+				pendingRetries = append(pendingRetries, item)
+				c.setRetrying(pendingRetries)
+			case <-ticker.C:
+				// Process pending retries
+				newPending := make([]retryItem, 0, len(pendingRetries))
+				for _, item := range pendingRetries {
+					// 一時停止中はリトライ回数を消費しないよう、再開まで待たせておく
+					if time.Since(item.lastAttempt) < retryDelay || c.isPaused() {
+						newPending = append(newPending, item)
+						continue
+					}
+
+					logger.Info("retrying upload", "file", item.filePath, "attempt", item.retryCount+1, "max_attempts", maxRetryCount+1)
+					uploadRetries.Inc()
+					url, err := c.uploadImage(item.filePath, retryWorkerID, item.retryCount+1)
+					if gyazo.IsUnauthorized(err) {
+						logger.Error("access token was rejected while retrying", "file", item.filePath, "error", err)
+						c.Pause(PauseUnauthorized)
+						newPending = append(newPending, item)
+						continue
+					}
+					if err != nil {
+						if item.retryCount < maxRetryCount {
+							item.retryCount++
+							item.lastAttempt = time.Now()
+							item.lastError = err.Error()
+							newPending = append(newPending, item)
+							logger.Warn("retry failed, will retry again", "file", item.filePath, "attempt", item.retryCount, "error", err)
+						} else {
+							logger.Error("max retries exceeded", "file", item.filePath, "attempt", item.retryCount+1, "error", err)
+							c.notify("アップロードに失敗しました", item.filePath)
+							c.addDeadLetter(item, err)
+							uploadsDropped.With(dropMaxRetries).Inc()
+							c.forget(item.filePath)
+						}
+					} else {
+						logger.Info("retry successful", "file", item.filePath, "attempt", item.retryCount+1, "url", url)
+						c.forget(item.filePath)
+						c.notify("アップロードしました", url)
+						if err := c.opener.Open(url); err != nil {
+							logger.Error("failed to open URL", "url", url, "error", err)
+						}
+					}
+				}
+				pendingRetries = newPending
+				c.setRetrying(pendingRetries)
+			case <-c.stopCh:
+				logger.Debug("retry worker stopping")
+				return
+			}
+		}
+	}()
+}
+
+// addDeadLetter はリトライしてもアップロードできなかった画像を記録する
+func (c *Uploader) addDeadLetter(item retryItem, err error) {
+	entry := deadletter.Entry{
+		FilePath: item.filePath,
+		Error:    err.Error(),
+		// 最初のアップロードとリトライの回数
+		Attempts: item.retryCount + 1,
+		FailedAt: time.Now(),
+	}
+	if err := c.deadLetters.Add(entry); err != nil {
+		logger.Error("failed to save dead letter", "file", item.filePath, "error", err)
+	}
+}
+
+// notify は notifier で通知する。通知の失敗はアップロード処理に影響させない
+func (c *Uploader) notify(title string, message string) {
+	if err := c.notifier.Notify(title, message); err != nil {
+		logger.Warn("failed to notify", "error", err)
+	}
+}
+
+// Stop gracefully shuts down the uploader
+// API などから Enqueue している途中でも安全なように、キューは閉じずに stopped と stopCh で停止を伝える
+func (c *Uploader) Stop() {
+	logger.Info("stopping uploader")
+	c.mu.Lock()
+	c.stopped = true
+	c.mu.Unlock()
+	close(c.stopCh)
+	c.wg.Wait()
+	c.retryWg.Wait()
+	logger.Info("uploader stopped")
+}
+
+// readFileWithRetry は、ファイルが他のプロセスによって使用されている場合にリトライする
+func openFileWithRetry(filePath string, retries int, delay time.Duration) (*os.File, error) {
+	var file *os.File
+	var err error
+
+	for i := 0; i < retries; i++ {
+		// ファイルを読み取り専用で開く
+		file, err = os.Open(filePath)
+		if err == nil {
+			// 成功したらファイルハンドラを返す
+			return file, nil
+		}
+
+		// エラーが "used by another process" かどうかを判定
+		// Windowsの特定のメッセージで判定しています。
+		if e, ok := err.(*os.PathError); ok && e.Err.Error() == "The process cannot access the file because it is being used by another process." {
+			logger.Debug("file is locked, will retry", "file", filePath, "attempt", i+1, "max_attempts", retries)
+			fmt.Printf("ファイルがロックされています。リトライします... (%d/%d)\n", i+1, retries)
+			// 指定された時間だけ待機
+			time.Sleep(delay)
+			continue
+		}
+
+		// その他のエラーの場合は即座にエラーを返す
+		return nil, err
+	}
+
+	// リトライがすべて失敗した場合、最後の具体的なエラーを返す
+	return nil, fmt.Errorf("リトライ回数の上限に達しました: %w", err)
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/zztkm/zgyazo/internal/auth"
+	"github.com/zztkm/zgyazo/internal/config"
+	"github.com/zztkm/zgyazo/internal/platform"
+	"github.com/zztkm/zgyazo/internal/secret"
+)
+
+// OAuth アプリケーションを指定する環境変数名
+const (
+	envClientID     = "ZGYAZO_CLIENT_ID"
+	envClientSecret = "ZGYAZO_CLIENT_SECRET"
+)
+
+// runLoginCommand は zgyazo login を実行し、終了コードを返す
+// ブラウザで Gyazo にログインしてアプリケーションを認可し、取得したトークンをキーストアに保存する
+func runLoginCommand(g *globalOptions, args []string) int {
+	fs := flag.NewFlagSet("login", flag.ContinueOnError)
+	clientID := fs.String("client-id", "", "OAuth アプリケーションの Client ID (config の oauth.client_id)")
+	port := fs.Int("port", 0, fmt.Sprintf("リダイレクトを待ち受けるポート (デフォルト: config の oauth.redirect_port か %d)", config.DefaultRedirectPort))
+	noBrowser := fs.Bool("no-browser", false, "ブラウザを開かず、認可ページの URL を表示するだけにする")
+	timeout := fs.Duration("timeout", 5*time.Minute, "認可を待つ時間")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: zgyazo login [flags]")
+		fmt.Fprintln(fs.Output(), "")
+		fmt.Fprintln(fs.Output(), "https://gyazo.com/oauth/applications に登録したアプリケーションでログインする")
+		fmt.Fprintf(fs.Output(), "アプリケーションの Callback URL には %s を登録する\n\n", auth.RedirectURL(config.DefaultRedirectPort))
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+	if err := g.setupCommandLogger(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	path := g.resolvedConfigPath()
+	cfg, profile, err := g.loadUnresolvedConfig(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		return 1
+	}
+	oauth, err := oauthSettings(cfg, *clientID)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if *port == 0 {
+		*port = oauth.Port()
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	ctx, cancelTimeout := context.WithTimeout(ctx, *timeout)
+	defer cancelTimeout()
+
+	opener := platform.New().Opener
+	token, err := auth.Login(ctx, oauth.Config(), *port, func(authURL string) error {
+		fmt.Printf("ブラウザで次の URL を開き、zgyazo を認可してください:\n\n  %s\n\n", authURL)
+		if !*noBrowser {
+			if err := opener.Open(authURL); err != nil {
+				// URL は表示しているので、手動で開いてもらえばよい
+				slog.Warn("failed to open the browser", "error", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Login failed: %v\n", err)
+		return 1
+	}
+
+	// 取得したトークンで API を使えるか確認する
+	merged, err := g.withProfile(cfg, profile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	merged.GyazoAccessToken = token.AccessToken
+	client, err := newGyazoClient(merged)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	verifyCtx, cancelVerify := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelVerify()
+	user, err := client.Me(verifyCtx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to verify the access token: %v\n", err)
+		return 1
+	}
+
+	name := keystoreTokenName
+	if profile != "" {
+		name += "-" + profile
+	}
+	ks := secret.DefaultKeystore()
+	if err := ks.Set(name, token.AccessToken); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to save the token to the keystore: %v\n", err)
+		return 1
+	}
+	// 参照が同じでも書き込み、常駐プロセスに設定ファイルを読み込み直させて新しいトークンを使わせる
+	if code := setAccessToken(path, profile, secret.KeystoreReference(name)); code != 0 {
+		return code
+	}
+
+	fmt.Printf("%s としてログインしました (トークンは %s に保存しました)\n", user.Name, ks.Path())
+	if os.Getenv(envToken) != "" {
+		fmt.Fprintf(os.Stderr, "Note: %s is set and takes precedence over the saved token\n", envToken)
+	}
+	if len(cfg.Watches) == 0 {
+		fmt.Printf("監視するフォルダを %s の watches に追加してください\n", path)
+	}
+	return 0
+}
+
+// runLogoutCommand は zgyazo logout を実行し、終了コードを返す
+// 失効エンドポイントが設定されている場合はトークンを失効させ、保存したトークンを削除する
+func runLogoutCommand(g *globalOptions, args []string) int {
+	if len(args) != 0 {
+		fmt.Fprintln(os.Stderr, "Usage: zgyazo logout")
+		return 2
+	}
+	if err := g.setupCommandLogger(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	path := g.resolvedConfigPath()
+	cfg, profile, err := g.loadUnresolvedConfig(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		return 1
+	}
+	value := accessTokenOf(cfg, profile)
+	if value == "" {
+		fmt.Println("Not logged in")
+		return 0
+	}
+
+	status := 0
+	if cfg.OAuth != nil && cfg.OAuth.RevokeURL != "" {
+		if err := revokeToken(cfg, value); err != nil {
+			// 失効に失敗しても、手元のトークンは削除する
+			fmt.Fprintf(os.Stderr, "Failed to revoke the token: %v\n", err)
+			status = 1
+		} else {
+			fmt.Println("Revoked the token")
+		}
+	} else {
+		fmt.Println("トークンはサーバー側では失効していません。Gyazo のアカウント設定からアプリケーションの連携を解除してください")
+	}
+
+	if name, ok := secret.KeystoreName(value); ok {
+		err := secret.DefaultKeystore().Delete(name)
+		if err != nil && !errors.Is(err, secret.ErrNotFound) {
+			fmt.Fprintf(os.Stderr, "Failed to delete %s from the keystore: %v\n", name, err)
+			return 1
+		}
+	} else if secret.IsReference(value) {
+		// 環境変数やパスワードマネージャーなど、zgyazo が管理していない場所のトークンは消せない
+		fmt.Printf("The token comes from %q; remove it there\n", value)
+		return status
+	}
+	if code := setAccessToken(path, profile, ""); code != 0 {
+		return code
+	}
+	fmt.Println("Logged out")
+	return status
+}
+
+// loadUnresolvedConfig は参照を解決せずに設定ファイルを読み込み、使うプロファイルの名前と一緒に返す
+// 設定ファイルがない場合は空の設定を返す
+func (g *globalOptions) loadUnresolvedConfig(path string) (*config.Config, string, error) {
+	cfg, err := config.LoadUnresolved(path)
+	if os.IsNotExist(err) {
+		cfg, err = &config.Config{Version: config.CurrentVersion}, nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	profile := firstNonEmpty(g.profile, os.Getenv(envProfile), cfg.Profile)
+	if _, ok := cfg.Profiles[profile]; profile != "" && !ok {
+		return nil, "", fmt.Errorf("unknown profile %q", profile)
+	}
+	return cfg, profile, nil
+}
+
+// accessTokenOf は profile (空の場合はトップレベル) の設定ファイル上の gyazo_access_token を返す
+func accessTokenOf(cfg *config.Config, profile string) string {
+	if profile == "" {
+		return cfg.GyazoAccessToken
+	}
+	return cfg.Profiles[profile].GyazoAccessToken
+}
+
+// oauthSettings は設定ファイルの oauth を環境変数とフラグで上書きし、参照を解決して返す
+func oauthSettings(cfg *config.Config, clientID string) (*config.OAuth, error) {
+	oauth := config.OAuth{}
+	if cfg.OAuth != nil {
+		oauth = *cfg.OAuth
+	}
+	oauth.ClientID = firstNonEmpty(clientID, os.Getenv(envClientID), oauth.ClientID)
+	if oauth.ClientID == "" {
+		return nil, fmt.Errorf("OAuth client ID is required; register an application at https://gyazo.com/oauth/applications "+
+			"with the callback URL %s and set oauth.client_id in the config file, %s or -client-id", auth.RedirectURL(oauth.Port()), envClientID)
+	}
+	clientSecret, err := secret.Resolve(firstNonEmpty(os.Getenv(envClientSecret), oauth.ClientSecret))
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve oauth.client_secret: %w", err)
+	}
+	oauth.ClientSecret = clientSecret
+	return &oauth, nil
+}
+
+// revokeToken は value (参照の場合は解決した値) のトークンを失効させる
+func revokeToken(cfg *config.Config, value string) error {
+	token, err := secret.Resolve(value)
+	if err != nil {
+		return err
+	}
+	oauth, err := oauthSettings(cfg, "")
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return auth.Revoke(ctx, oauth.RevokeURL, oauth.Config(), token)
+}
+
+// setAccessToken は設定ファイルの gyazo_access_token を value に書き換え、終了コードを返す
+// 自動で書き換えられない形式の場合は、書き換え方を表示する
+func setAccessToken(path, profile, value string) int {
+	err := config.SetAccessToken(path, profile, value)
+	if errors.Is(err, config.ErrUnsupportedFormat) {
+		field := "gyazo_access_token"
+		if profile != "" {
+			field = "profiles." + profile + ".gyazo_access_token"
+		}
+		fmt.Printf("Set %s to %q in %s\n", field, value, path)
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to update %s: %v\n", path, err)
+		return 1
+	}
+	fmt.Printf("Updated %s\n", path)
+	return 0
+}
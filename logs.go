@@ -0,0 +1,158 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/zztkm/zgyazo/internal/logging"
+	"github.com/zztkm/zgyazo/internal/platform"
+)
+
+// runLogsCommand は zgyazo logs を実行し、終了コードを返す
+// -export を指定した場合は、トークンや個人的なパスを伏せたログを zip にまとめる
+func runLogsCommand(g *globalOptions, args []string) int {
+	fs := flag.NewFlagSet("logs", flag.ContinueOnError)
+	export := fs.Bool("export", false, "トークンやホームディレクトリのパスを伏せたログと設定を zip にまとめる")
+	output := fs.String("o", "", "-export で作成するファイルのパス (デフォルト: zgyazo-logs-<日時>.zip)")
+	keepHome := fs.Bool("keep-home", false, "-export でホームディレクトリのパスを伏せない")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: zgyazo logs [-export [-o file] [-keep-home]]")
+		fmt.Fprintln(fs.Output(), "")
+		fmt.Fprintln(fs.Output(), "ログファイルのパスを表示する。-export を指定すると、バグ報告に添付できるようにまとめる")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+	if err := g.setupCommandLogger(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	files, err := logging.Files(platform.LogPath())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if !*export {
+		if len(files) == 0 {
+			fmt.Fprintf(os.Stderr, "No log files in %s\n", platform.StateDir())
+			return 1
+		}
+		for _, f := range files {
+			fmt.Println(f)
+		}
+		return 0
+	}
+
+	path := *output
+	if path == "" {
+		path = fmt.Sprintf("zgyazo-logs-%s.zip", time.Now().Format("20060102-150405"))
+	}
+	if err := exportLogs(g, path, files, !*keepHome); err != nil {
+		os.Remove(path)
+		fmt.Fprintf(os.Stderr, "Failed to export logs: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Exported logs to %s\n", path)
+	fmt.Println("トークンと Authorization ヘッダーは伏せていますが、共有する前に内容を確認してください")
+	return 0
+}
+
+// exportLogs はログファイル、設定、実行環境を伏せる値を伏せて path の zip に書き込む
+func exportLogs(g *globalOptions, path string, files []string, redactHome bool) error {
+	// 設定を読み込めない場合もログは書き出す。トークンはパターンに一致するものだけ伏せる
+	var secrets []string
+	cfg, cfgErr := g.loadConfig()
+	if cfgErr == nil {
+		secrets = cfg.Secrets()
+	}
+	redactor := logging.NewRedactor(secrets, redactHome)
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+
+	for _, file := range files {
+		if err := addRedactedFile(zw, redactor, file); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+	}
+
+	configJSON := []byte(fmt.Sprintf("failed to load config: %v\n", cfgErr))
+	if cfgErr == nil {
+		if configJSON, err = json.MarshalIndent(cfg.Masked(), "", "  "); err != nil {
+			return err
+		}
+	}
+	info := fmt.Sprintf("version: %s\nos: %s/%s\ngo: %s\nexported at: %s\n",
+		versionString(), runtime.GOOS, runtime.GOARCH, runtime.Version(), time.Now().Format(time.RFC3339))
+	for name, content := range map[string]string{"config.json": string(configJSON), "info.txt": info} {
+		w, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, redactor.String(content)); err != nil {
+			return err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// addRedactedFile は file を 1 行ずつ伏せて zw に追加する
+// gzip で圧縮したバックアップファイルは展開してから伏せる
+func addRedactedFile(zw *zip.Writer, redactor *logging.Redactor, file string) error {
+	in, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	var src io.Reader = in
+	name := filepath.Base(file)
+	if strings.HasSuffix(name, ".gz") {
+		gz, err := gzip.NewReader(in)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		src, name = gz, strings.TrimSuffix(name, ".gz")
+	}
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	r := bufio.NewReader(src)
+	for {
+		line, err := r.ReadString('\n')
+		if _, werr := io.WriteString(w, redactor.String(line)); werr != nil {
+			return werr
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
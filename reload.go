@@ -0,0 +1,308 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/zztkm/zgyazo/internal/config"
+	"github.com/zztkm/zgyazo/internal/logging"
+	"github.com/zztkm/zgyazo/internal/platform"
+	"github.com/zztkm/zgyazo/internal/uploader"
+)
+
+// configReloadDelay は設定ファイルの変更を検出してから読み込むまでの待ち時間
+// エディタは 1 回の保存で複数回書き込むことがあるため、変更が落ち着いてから読み込む
+const configReloadDelay = 500 * time.Millisecond
+
+// configReloader は設定ファイルの変更やプロファイルの切り替えを、再起動せずに常駐プロセスに反映する
+// 新しい設定に問題がある場合は反映せず、それまでの設定で動作を続ける
+type configReloader struct {
+	g    *globalOptions
+	path string
+
+	// mu は設定ファイルの再読み込みとプロファイルの切り替えが同時に行われないようにする
+	mu sync.Mutex
+
+	// current は反映済みの設定
+	current *config.Config
+
+	// profile はホットキーで切り替えたプロファイル
+	// 空の場合は設定ファイルやフラグで指定したプロファイルを使う
+	profile string
+
+	uploader   *uploader.Uploader
+	undo       *undoer
+	platform   *platform.Platform
+	checker    *tokenChecker
+	logRotator *logging.Rotator
+}
+
+// watch は設定ファイルを監視し、変更されるたびに設定を読み込み直す
+// stopCh が閉じられるまでブロックする
+func (r *configReloader) watch(stopCh <-chan struct{}) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	// 保存時に一時ファイルから置き換えられると設定ファイル自体の監視は外れてしまうため、ディレクトリを監視する
+	if err := watcher.Add(filepath.Dir(r.path)); err != nil {
+		return err
+	}
+	slog.Debug("watching config file", "file", r.path)
+
+	timer := time.NewTimer(configReloadDelay)
+	timer.Stop()
+	defer timer.Stop()
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if filepath.Clean(event.Name) != filepath.Clean(r.path) || !event.Has(fsnotify.Write|fsnotify.Create) {
+				continue
+			}
+			slog.Debug("config file event", "file", event.Name, "op", event.Op.String())
+			timer.Reset(configReloadDelay)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			slog.Warn("config watcher error", "error", err)
+		case <-timer.C:
+			r.reload()
+		case <-stopCh:
+			return nil
+		}
+	}
+}
+
+// reload は設定ファイルを読み込み、問題がなければ反映する
+func (r *configReloader) reload() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	slog.Info("config file changed, reloading", "file", r.path)
+	cfg, err := r.g.loadConfig()
+	if err == nil && r.profile != "" {
+		// ホットキーで切り替えたプロファイルを使い続ける
+		if _, ok := cfg.Profiles[r.profile]; ok {
+			cfg, err = r.g.withProfile(cfg, r.profile)
+		} else {
+			slog.Warn("profile was removed from the config, switching back", "profile", r.profile, "to", cfg.Profile)
+			r.profile = ""
+		}
+	}
+	if err == nil {
+		err = cfg.Validate(r.path)
+	}
+	if err == nil {
+		err = r.apply(cfg)
+	}
+	if err != nil {
+		slog.Error("failed to reload config, keeping the current config", "file", r.path, "error", err)
+		r.notify("設定ファイルを反映できませんでした", "変更前の設定で動作を続けます")
+		return
+	}
+	r.current = cfg
+	slog.Info("config reloaded", "file", r.path)
+}
+
+// switchProfile は使うプロファイルを名前順で次のプロファイルに切り替える
+// 切り替えたプロファイルは設定ファイルには保存せず、常駐プロセスを終了するまで使う
+func (r *configReloader) switchProfile() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := r.current.ProfileNames()
+	if len(names) == 0 {
+		return
+	}
+	// 使用中のプロファイルがない場合は Index が -1 を返すので、最初のプロファイルになる
+	next := names[(slices.Index(names, r.current.Profile)+1)%len(names)]
+	cfg, err := r.g.withProfile(r.current, next)
+	if err == nil {
+		err = cfg.Validate(r.path)
+	}
+	if err == nil {
+		err = r.apply(cfg)
+	}
+	if err != nil {
+		slog.Error("failed to switch profile", "profile", next, "error", err)
+		r.notify("プロファイルを切り替えられませんでした", next)
+		return
+	}
+	r.current = cfg
+	r.profile = next
+	slog.Info("switched profile", "profile", next)
+	r.notify("プロファイルを切り替えました", next)
+}
+
+// currentProfile は使用中のプロファイルを返す
+func (r *configReloader) currentProfile() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current.Profile
+}
+
+// accountForProfile は profile のプロファイルでアップロードするアカウントを返す
+// 使用中のプロファイルの場合は、使用中のアカウントを返す
+func (r *configReloader) accountForProfile(profile string) (*uploader.Account, error) {
+	r.mu.Lock()
+	cfg := r.current
+	r.mu.Unlock()
+	if profile == cfg.Profile {
+		return r.uploader.Accounts()[0], nil
+	}
+	profileConfig, err := r.g.withProfile(cfg, profile)
+	if err != nil {
+		return nil, err
+	}
+	return newAccount(profileConfig)
+}
+
+// hotkeys は cfg に従って常駐プロセスで監視するホットキーを返す
+func (r *configReloader) hotkeys(cfg *config.Config) []platform.Hotkey {
+	hotkeys := []platform.Hotkey{
+		{
+			Keys: cfg.Hotkeys.CaptureKeys(),
+			// キャプチャツールが終了するまで Launch は戻らないため、ホットキーのメッセージループを止めないように別の goroutine で起動する
+			Action: func() {
+				go func() {
+					slog.Info("capture hotkey pressed, launching capture tool")
+					if err := r.platform.Launcher.Launch(); err != nil {
+						slog.Error("failed to launch capture tool", "error", err)
+					}
+				}()
+			},
+		},
+		{
+			Keys:   cfg.Hotkeys.UndoKeys(),
+			Action: r.undo.undoLast,
+		},
+	}
+	if len(cfg.Profiles) > 0 {
+		hotkeys = append(hotkeys, platform.Hotkey{
+			Keys: cfg.Hotkeys.SwitchProfileKeys(),
+			// ホットキーのメッセージループを止めないように別の goroutine で切り替える
+			Action: func() { go r.switchProfile() },
+		})
+	}
+	if keys := cfg.Hotkeys.PauseKeys(); keys != "" {
+		hotkeys = append(hotkeys, platform.Hotkey{
+			Keys:   keys,
+			Action: func() { go r.togglePause() },
+		})
+	}
+	return hotkeys
+}
+
+// pauseUploads はユーザーの操作でアップロードを一時停止する
+// d が 0 の場合は設定ファイルの pause_auto_resume が経過したら自動で再開する
+func (r *configReloader) pauseUploads(d time.Duration) {
+	if d == 0 {
+		r.mu.Lock()
+		d = r.current.AutoResumeAfter()
+		r.mu.Unlock()
+	}
+	r.uploader.PauseFor(uploader.PauseUser, d)
+}
+
+// togglePause はユーザーの操作による一時停止と再開を切り替える
+func (r *configReloader) togglePause() {
+	if r.uploader.IsPaused(uploader.PauseUser) {
+		slog.Info("pause hotkey pressed, resuming uploads")
+		r.uploader.Resume(uploader.PauseUser)
+		return
+	}
+	slog.Info("pause hotkey pressed, pausing uploads")
+	r.pauseUploads(0)
+}
+
+// apply は前回から変わった設定を反映する
+// 失敗する可能性のある変更を先に行い、失敗した場合は何も変更せずにエラーを返す
+func (r *configReloader) apply(cfg *config.Config) error {
+	old := r.current
+
+	var account *uploader.Account
+	if cfg.GyazoAccessToken != old.GyazoAccessToken || cfg.Endpoint != old.Endpoint ||
+		cfg.AccessPolicy != old.AccessPolicy || cfg.Profile != old.Profile {
+		var err error
+		account, err = newAccount(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to create Gyazo client: %w", err)
+		}
+	}
+
+	// プロファイルごとのトークンが変わった場合にも対応できるよう、監視ディレクトリのアカウントは毎回作り直す
+	watches, err := r.g.uploaderWatches(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create Gyazo client: %w", err)
+	}
+	if err := r.uploader.SetWatches(watches); err != nil {
+		return fmt.Errorf("failed to update watch directories: %w", err)
+	}
+
+	if account != nil {
+		r.uploader.SetAccount(account)
+		slog.Info("Gyazo client updated", "profile", cfg.Profile)
+	}
+	// トークンが更新されていれば、無効なトークンで一時停止していたアップロードを再開する
+	r.checker.checkNow()
+
+	if cfg.Workers() != old.Workers() {
+		r.uploader.SetWorkerCount(cfg.Workers())
+		slog.Info("worker count changed", "from", old.Workers(), "to", cfg.Workers())
+	}
+
+	if cfg.Hotkeys != old.Hotkeys || (len(cfg.Profiles) > 0) != (len(old.Profiles) > 0) {
+		// 設定の検証でキーは確認済みのため、ここで失敗するのは他のアプリが同じキーを使っている場合
+		// 他の変更は反映済みなので、ホットキーだけ元のまま動作を続ける
+		r.undo.setKeys(cfg.Hotkeys.UndoKeys())
+		if err := r.platform.Hotkeys.Update(r.hotkeys(cfg)); err != nil {
+			slog.Error("failed to update hotkeys", "error", err)
+		} else {
+			slog.Info("hotkeys changed", "capture", cfg.Hotkeys.CaptureKeys(), "undo", cfg.Hotkeys.UndoKeys(), "pause", cfg.Hotkeys.PauseKeys())
+		}
+	}
+
+	// トークンが変わった場合も新しいトークンを伏せるように、毎回設定し直す
+	// 検証済みなのでエラーにはならない
+	opts, _ := cfg.LogOptions()
+	logging.SetOptions(opts)
+	if cfg.LogLevel != old.LogLevel || cfg.LogFormat != old.LogFormat || !maps.Equal(cfg.LogLevels, old.LogLevels) {
+		slog.Info("logging changed", "level", opts.Level, "format", opts.Format)
+	}
+	if cfg.PauseAutoResume != old.PauseAutoResume {
+		// 一時停止中の自動で再開するタイマーはそのままにし、次に一時停止したときから反映する
+		slog.Info("pause auto-resume changed", "after", cfg.AutoResumeAfter())
+	}
+	if !reflect.DeepEqual(cfg.API, old.API) {
+		slog.Warn("api settings changed, restart zgyazo to apply them")
+	}
+	if !reflect.DeepEqual(cfg.Metrics, old.Metrics) {
+		slog.Warn("metrics settings changed, restart zgyazo to apply them")
+	}
+	if cfg.LogFile != old.LogFile {
+		rotation := cfg.LogRotation()
+		r.logRotator.SetOptions(rotation)
+		slog.Info("log rotation changed", "rotate", rotation.Rotate, "max_size", rotation.MaxSize,
+			"max_backups", rotation.MaxBackups, "max_age", rotation.MaxAge, "compress", rotation.Compress)
+	}
+	return nil
+}
+
+func (r *configReloader) notify(title string, message string) {
+	if err := r.platform.Notifier.Notify(title, message); err != nil {
+		slog.Warn("failed to notify", "error", err)
+	}
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/zztkm/zgyazo/internal/api"
+	"github.com/zztkm/zgyazo/internal/config"
+	"github.com/zztkm/zgyazo/internal/deadletter"
+	"github.com/zztkm/zgyazo/internal/history"
+	"github.com/zztkm/zgyazo/internal/instance"
+	"github.com/zztkm/zgyazo/internal/logging"
+	"github.com/zztkm/zgyazo/internal/metrics"
+	"github.com/zztkm/zgyazo/internal/platform"
+	"github.com/zztkm/zgyazo/internal/queue"
+	"github.com/zztkm/zgyazo/internal/uploader"
+)
+
+// runDaemon は zgyazo run を実行する
+// ディレクトリの監視とホットキーの監視を行い、シグナルを受け取るまでブロックする
+func runDaemon(g *globalOptions, args []string) int {
+	if len(args) > 0 {
+		fmt.Fprintf(os.Stderr, "Unexpected arguments for run: %v\n", args)
+		return 2
+	}
+
+	// 設定・状態ディレクトリを最初に作成
+	if err := platform.EnsureDirs(); err != nil {
+		slog.Error("failed to create config directory", "error", err)
+		return 1
+	}
+
+	// 設定ファイルを読み込むまでは、フラグと環境変数で指定したログの設定を使う
+	logOpts, err := g.commandLogOptions()
+	if err != nil {
+		slog.Error("invalid logging options", "error", err)
+		return 1
+	}
+	logRotator, err := logging.Setup(platform.LogPath(), logOpts)
+	if err != nil {
+		slog.Error("failed to setup logger", "error", err)
+		return 1
+	}
+	defer func() {
+		logRotator.Sync()
+		logRotator.Close()
+	}()
+
+	// ログファイルを定期的にフラッシュする（5秒ごと）
+	logging.StartFlusher(logRotator, 5*time.Second)
+
+	// serveDaemon の defer でロックの解放やサーバーの停止を済ませてから、ログを出力して終了する
+	if err := serveDaemon(g, logRotator); err != nil {
+		var de *daemonError
+		if errors.As(err, &de) {
+			slog.Error(de.msg, de.args...)
+		} else {
+			slog.Error("zgyazo stopped with an error", "error", err)
+		}
+		return 1
+	}
+	slog.Info("zgyazo stopped")
+	return 0
+}
+
+// daemonError は常駐プロセスを終了させるエラー
+// ログの属性を保持しておき、runDaemon が後片付けを済ませてから出力する
+type daemonError struct {
+	msg  string
+	args []any
+}
+
+func (e *daemonError) Error() string {
+	return e.msg
+}
+
+// fail は msg と属性 args をログに出力して終了する daemonError を返す
+func fail(msg string, args ...any) error {
+	return &daemonError{msg: msg, args: args}
+}
+
+// serveDaemon は常駐プロセスの本体
+// シグナルを受け取ると nil を返し、起動や実行に失敗した場合は daemonError を返す
+func serveDaemon(g *globalOptions, logRotator *logging.Rotator) error {
+	// 2 つ目の常駐プロセスが起動すると、同じ画像を 2 回アップロードしてしまう
+	// スタートアップと手動で 2 回起動された場合などは、ホットキーの登録で失敗する前に終了する
+	lock, err := instance.Acquire(instance.PIDPath())
+	var running *instance.RunningError
+	if errors.As(err, &running) {
+		return fail("zgyazo is already running, exiting", "pid", running.PID)
+	}
+	if err != nil {
+		return fail("failed to create lock file", "file", instance.PIDPath(), "error", err)
+	}
+	defer lock.Release()
+
+	startedAt := time.Now()
+
+	// シグナルハンドリングの設定
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+
+	slog.Info("starting zgyazo", "version", versionString(), "log_file", platform.LogPath())
+	slog.Debug("app directories", "config_dir", platform.ConfigDir(), "state_dir", platform.StateDir())
+
+	config_path := g.resolvedConfigPath()
+	if _, err := os.Stat(config_path); os.IsNotExist(err) {
+		// 空の設定ファイルを作っても起動できないので、作成方法を案内して終了する
+		return fail("config file does not exist; run 'zgyazo config init' to create it", "file", config_path)
+	}
+	cfg, err := g.loadConfig()
+	if err != nil {
+		return fail("failed to load config", "file", config_path, "error", err)
+	}
+	// 監視を始める前に設定の問題をまとめて報告する
+	if err := cfg.Validate(config_path); err != nil {
+		return fail("invalid config", "file", config_path, "error", err)
+	}
+	// 設定ファイルでログの設定が指定されている場合はここから反映する
+	logOpts, err := cfg.LogOptions()
+	if err != nil {
+		return fail("invalid logging options", "error", err)
+	}
+	logging.SetOptions(logOpts)
+	logRotator.SetOptions(cfg.LogRotation())
+	slog.Info("loaded config", "file", config_path, "watches", cfg.WatchPaths(), "profile", cfg.Profile)
+
+	p := platform.New()
+
+	account, err := newAccount(cfg)
+	if err != nil {
+		return fail("failed to create Gyazo client", "error", err)
+	}
+	watches, err := g.uploaderWatches(cfg)
+	if err != nil {
+		return fail("failed to create Gyazo client", "error", err)
+	}
+
+	// トークンが無効なまま監視を始めると、すべてのアップロードがリトライの末に失敗するため、先に確認する
+	if err := checkAccountsAtStartup(accountsOf(account, watches)); err != nil {
+		return fail("cannot start with the current access token", "error", err)
+	}
+
+	hist, err := history.Open(history.DefaultPath())
+	if err != nil {
+		return fail("failed to open history", "error", err)
+	}
+	dead, err := deadletter.Open(deadletter.DefaultPath())
+	if err != nil {
+		return fail("failed to open dead letters", "error", err)
+	}
+	pending, err := queue.Open(queue.DefaultPath())
+	if err != nil {
+		return fail("failed to open upload queue", "error", err)
+	}
+	up := uploader.New(account, watches, p.Opener, p.Notifier, hist, dead, pending)
+	up.SetWorkerCount(cfg.Workers())
+	undo := &undoer{history: hist, notifier: p.Notifier, keys: cfg.Hotkeys.UndoKeys()}
+
+	// up.Run() とホットキーの監視を平行実行する
+	uploaderErr := make(chan error, 1)
+	go func() {
+		if err := up.Run(); err != nil {
+			uploaderErr <- fail("failed to run uploader", "error", err)
+		}
+	}()
+
+	// トークンの失効に備えて定期的に確認する
+	checker := newTokenChecker(up)
+	stopChecker := make(chan struct{})
+	go checker.run(stopChecker)
+
+	// 設定ファイルの変更を監視し、再起動せずに反映する
+	reloader := &configReloader{g: g, path: config_path, current: cfg, uploader: up, undo: undo, platform: p, checker: checker, logRotator: logRotator}
+	undo.accountFor = reloader.accountForProfile
+	stopReloader := make(chan struct{})
+	go func() {
+		if err := reloader.watch(stopReloader); err != nil {
+			slog.Warn("failed to watch config file, changes will not be applied until restart", "file", config_path, "error", err)
+		}
+	}()
+
+	// 起動の途中で失敗した場合も、ここまでに始めた処理を止めてから終了する
+	// exitErr は停止の原因になったエラー (シグナルを受け取った場合は nil)
+	var (
+		shutdownOnce  sync.Once
+		exitErr       error
+		controlServer *instance.Server
+		apiServer     *api.Server
+		metricsServer *metrics.Server
+	)
+	shutdown := func(cause error) {
+		shutdownOnce.Do(func() {
+			exitErr = cause
+			// 画像を追加する API とコントロールサーバーを先に止めてから、Uploader を停止する
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if apiServer != nil {
+				if err := apiServer.Shutdown(ctx); err != nil {
+					slog.Warn("failed to stop API server", "error", err)
+				}
+			}
+			if metricsServer != nil {
+				if err := metricsServer.Shutdown(ctx); err != nil {
+					slog.Warn("failed to stop metrics server", "error", err)
+				}
+			}
+			cancel()
+			if controlServer != nil {
+				if err := controlServer.Close(); err != nil {
+					slog.Warn("failed to stop listening for control requests", "error", err)
+				}
+			}
+			close(stopReloader)
+			close(stopChecker)
+
+			// Uploader を停止
+			up.Stop()
+			slog.Debug("uploader stopped")
+
+			// ホットキーの監視を止めると serveDaemon が戻り、ログファイルがフラッシュされる
+			p.Hotkeys.Stop()
+		})
+	}
+	defer shutdown(nil)
+
+	// zgyazo ctl や zgyazo upload などの別のプロセスからの要求を受け付ける
+	daemon := &daemonAPI{uploader: up, history: hist, deadLetters: dead, reloader: reloader, startedAt: startedAt, sigChan: sigChan}
+	controlServer, err = startControlServer(daemon)
+	if err != nil {
+		return fail("failed to listen for control requests", "address", instance.Address(), "error", err)
+	}
+
+	// API を有効にしている場合は、状態の確認や操作を受け付ける
+	apiServer, err = startAPIServer(cfg, daemon)
+	if err != nil {
+		return fail("failed to start API server", "error", err)
+	}
+
+	// メトリクスを有効にしている場合は、Prometheus 形式で公開する
+	if cfg.Metrics != nil {
+		metricsServer = metrics.NewServer(cfg.Metrics.ListenPort())
+		if err := metricsServer.Start(); err != nil {
+			return fail("failed to start metrics server", "error", err)
+		}
+	}
+
+	// シグナルを受け取るか Uploader が失敗すると、常駐プロセスを停止する
+	go func() {
+		select {
+		case sig := <-sigChan:
+			slog.Info("received signal, shutting down gracefully", "signal", sig.String())
+			shutdown(nil)
+		case err := <-uploaderErr:
+			shutdown(err)
+		}
+	}()
+
+	// このサービスで処理終了をブロックする
+	if err := p.Hotkeys.Run(reloader.hotkeys(cfg)); err != nil {
+		return fail("failed to run shortcut key service", "error", err)
+	}
+	// 停止が終わるのを待ってから、その原因を返す
+	shutdown(nil)
+	return exitErr
+}
+
+// newAccount は cfg のトークンと公開範囲でアップロードするアカウントを生成する
+func newAccount(cfg *config.Config) (*uploader.Account, error) {
+	client, err := newGyazoClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	opts := uploader.DefaultUploadOptions()
+	if cfg.AccessPolicy != "" {
+		opts.AccessPolicy = cfg.AccessPolicy
+	}
+	return &uploader.Account{Profile: cfg.Profile, Client: client, Options: opts}, nil
+}
+
+// uploaderWatches は cfg の watches を Uploader の監視ディレクトリに変換する
+// 使用中とは別のプロファイルが指定されたディレクトリには、そのプロファイルのアカウントを設定する
+func (g *globalOptions) uploaderWatches(cfg *config.Config) ([]uploader.Watch, error) {
+	watches := make([]uploader.Watch, len(cfg.Watches))
+	for i, w := range cfg.Watches {
+		watches[i].Path = w.Path
+		if w.Profile == "" || w.Profile == cfg.Profile {
+			continue
+		}
+		profileConfig, err := g.withProfile(cfg, w.Profile)
+		if err != nil {
+			return nil, err
+		}
+		if watches[i].Account, err = newAccount(profileConfig); err != nil {
+			return nil, fmt.Errorf("profile %s: %w", w.Profile, err)
+		}
+	}
+	return watches, nil
+}
+
+// accountsOf は account と、watches の監視ディレクトリごとのアカウントを返す
+// 同じプロファイルのアカウントは 1 つにまとめる
+func accountsOf(account *uploader.Account, watches []uploader.Watch) []*uploader.Account {
+	accounts := []*uploader.Account{account}
+	for _, w := range watches {
+		if w.Account != nil && !slices.ContainsFunc(accounts, func(a *uploader.Account) bool { return a.Profile == w.Account.Profile }) {
+			accounts = append(accounts, w.Account)
+		}
+	}
+	return accounts
+}
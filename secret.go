@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+
+	"github.com/zztkm/zgyazo/internal/secret"
+)
+
+// runSecretCommand は zgyazo secret <subcommand> を実行し、終了コードを返す
+// 設定ファイルには "keystore:<name>" と書いて、キーストアに保存した値を参照する
+func runSecretCommand(g *globalOptions, args []string) int {
+	usage := func() {
+		fmt.Fprintln(os.Stderr, "Usage: zgyazo secret <command>")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Commands:")
+		fmt.Fprintln(os.Stderr, "  set <name>     値を暗号化してキーストアに保存する (入力は表示しない)")
+		fmt.Fprintln(os.Stderr, "  delete <name>  キーストアから値を削除する")
+		fmt.Fprintln(os.Stderr, "  list           キーストアに保存されている名前を表示する")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, `設定ファイルでは "gyazo_access_token": "keystore:<name>" のように参照する`)
+	}
+	if len(args) == 0 {
+		usage()
+		return 2
+	}
+	if err := g.setupCommandLogger(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	ks := secret.DefaultKeystore()
+	switch {
+	case args[0] == "set" && len(args) == 2:
+		value, err := readSecret(bufio.NewReader(os.Stdin), "Value: ")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if value == "" {
+			fmt.Fprintln(os.Stderr, "value must not be empty")
+			return 1
+		}
+		if err := ks.Set(args[1], value); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to save %s: %v\n", args[1], err)
+			return 1
+		}
+		fmt.Printf("Saved %s to %s\n", args[1], ks.Path())
+		fmt.Printf("Use \"keystore:%s\" in the config file to refer to it\n", args[1])
+		return 0
+	case args[0] == "delete" && len(args) == 2:
+		if err := ks.Delete(args[1]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("Deleted %s\n", args[1])
+		return 0
+	case args[0] == "list" && len(args) == 1:
+		names, err := ks.Names()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		for _, name := range names {
+			fmt.Println(name)
+		}
+		return 0
+	default:
+		usage()
+		return 2
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/zztkm/zgyazo/gyazo"
+	"github.com/zztkm/zgyazo/internal/uploader"
+)
+
+const (
+	// tokenCheckInterval はアクセストークンが有効かを確認する間隔
+	tokenCheckInterval = 15 * time.Minute
+
+	// tokenRecheckInterval はトークンが無効で一時停止している間に確認する間隔
+	// サーバー側の一時的な問題で 401 になった場合も早めに再開できるようにする
+	tokenRecheckInterval = time.Minute
+
+	// tokenCheckTimeout はユーザー情報の取得を待つ時間
+	tokenCheckTimeout = 10 * time.Second
+)
+
+// verifyAccount はユーザー情報を取得して account のトークンが有効かを確認する
+func verifyAccount(account *uploader.Account) (*gyazo.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), tokenCheckTimeout)
+	defer cancel()
+	return account.Client.Me(ctx)
+}
+
+// describeAccount は zgyazo doctor で表示するアカウントの説明を返す
+// プロファイルは診断結果の対象として表示する
+func describeAccount(user *gyazo.User) string {
+	return fmt.Sprintf("%s (plan: %s)", user.Name, firstNonEmpty(user.Plan, "unknown"))
+}
+
+// accountAttrs はログに出力するアカウントの属性を返す
+func accountAttrs(account *uploader.Account, user *gyazo.User) []any {
+	return []any{"user", user.Name, "plan", firstNonEmpty(user.Plan, "unknown"), "profile", account.Profile}
+}
+
+// tokenError はトークンの確認に失敗した理由を、直し方が分かるエラーにする
+func tokenError(account *uploader.Account, err error) error {
+	if !gyazo.IsUnauthorized(err) {
+		return err
+	}
+	field := "gyazo_access_token"
+	if account.Profile != "" {
+		field = "profiles." + account.Profile + ".gyazo_access_token"
+	}
+	return fmt.Errorf("the access token was rejected (401); it may be invalid or revoked. Run 'zgyazo login' or update %s: %w", field, err)
+}
+
+// checkAccountsAtStartup は監視を始める前にすべてのアカウントのトークンを確認する
+// トークンが無効な場合はエラーを返す。ネットワークに接続できないなどの場合は起動を続け、定期的な確認に任せる
+func checkAccountsAtStartup(accounts []*uploader.Account) error {
+	for _, account := range accounts {
+		user, err := verifyAccount(account)
+		if gyazo.IsUnauthorized(err) {
+			return tokenError(account, err)
+		}
+		if err != nil {
+			slog.Warn("could not verify the access token, continuing", "profile", account.Profile, "error", err)
+			continue
+		}
+		slog.Info("logged in to Gyazo", accountAttrs(account, user)...)
+	}
+	return nil
+}
+
+// tokenChecker はアクセストークンが有効かを定期的に確認する
+// 無効な場合はアップロードを一時停止し、すべてのリトライを失敗させないようにする
+type tokenChecker struct {
+	uploader *uploader.Uploader
+
+	// checkCh に送ると次の間隔を待たずに確認する
+	checkCh chan struct{}
+}
+
+func newTokenChecker(up *uploader.Uploader) *tokenChecker {
+	return &tokenChecker{uploader: up, checkCh: make(chan struct{}, 1)}
+}
+
+// run は stopCh が閉じられるまで定期的にトークンを確認する
+// トークンが無効で一時停止している間は、より短い間隔で確認する
+func (t *tokenChecker) run(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(tokenRecheckInterval)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-ticker.C:
+			if !t.uploader.IsPaused(uploader.PauseUnauthorized) && time.Since(last) < tokenCheckInterval {
+				continue
+			}
+		case <-t.checkCh:
+		case <-stopCh:
+			return
+		}
+		t.check()
+		last = time.Now()
+	}
+}
+
+// checkNow は次の間隔を待たずにトークンを確認させる
+// 設定の再読み込みでトークンが変わった場合に呼び出す
+func (t *tokenChecker) checkNow() {
+	select {
+	case t.checkCh <- struct{}{}:
+	default:
+	}
+}
+
+// check はすべてのアカウントのトークンを確認し、一時停止・再開する
+// ネットワークのエラーなどでトークンの有効性が分からない場合は状態を変えない
+func (t *tokenChecker) check() {
+	unauthorized, unknown := false, false
+	for _, account := range t.uploader.Accounts() {
+		user, err := verifyAccount(account)
+		switch {
+		case gyazo.IsUnauthorized(err):
+			slog.Error("access token was rejected", "profile", account.Profile, "error", tokenError(account, err))
+			unauthorized = true
+		case err != nil:
+			slog.Warn("could not verify the access token", "profile", account.Profile, "error", err)
+			unknown = true
+		default:
+			slog.Debug("access token is valid", accountAttrs(account, user)...)
+		}
+	}
+	switch {
+	case unauthorized:
+		t.uploader.Pause(uploader.PauseUnauthorized)
+	case !unknown:
+		t.uploader.Resume(uploader.PauseUnauthorized)
+	}
+}
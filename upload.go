@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/zztkm/zgyazo/gyazo"
+	"github.com/zztkm/zgyazo/internal/config"
+	"github.com/zztkm/zgyazo/internal/platform"
+	"github.com/zztkm/zgyazo/internal/uploader"
+)
+
+// uploadResult は zgyazo upload の 1 ファイル分の結果
+type uploadResult struct {
+	File         string `json:"file"`
+	ImageID      string `json:"image_id,omitempty"`
+	PermalinkURL string `json:"permalink_url,omitempty"`
+	URL          string `json:"url,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// runUploadCommand は zgyazo upload [files...|-] を実行し、終了コードを返す
+// 1 つでもアップロードに失敗した場合は 1 を返す
+func runUploadCommand(g *globalOptions, args []string) int {
+	fs := flag.NewFlagSet("upload", flag.ContinueOnError)
+	openURL := fs.Bool("open", false, "アップロードした画像をブラウザで開く")
+	desc := fs.String("desc", "", "画像の説明")
+	accessPolicy := fs.String("access-policy", "", "画像の公開範囲 (anyone, only_me)。省略時は config の access_policy (未設定なら anyone)")
+	format := fs.String("format", "", "出力形式 (url, json, markdown)。省略時は config の output_format")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: zgyazo upload [flags] <file...|->")
+		fmt.Fprintln(fs.Output(), "  - を指定すると標準入力から読み込んだ画像をアップロードする")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	if err := g.setupCommandLogger(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	env, err := loadCommandEnv(g)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if *format == "" {
+		*format = env.config.OutputFormat
+	}
+	if *format == "" {
+		*format = config.OutputFormatURL
+	}
+	if *format != config.OutputFormatURL && *format != config.OutputFormatJSON && *format != config.OutputFormatMarkdown {
+		fmt.Fprintf(os.Stderr, "Invalid output format: %s\n", *format)
+		return 2
+	}
+	*accessPolicy = firstNonEmpty(*accessPolicy, env.config.AccessPolicy, gyazo.AccessPolicyAnyone)
+	if *accessPolicy != gyazo.AccessPolicyAnyone && *accessPolicy != gyazo.AccessPolicyOnlyMe {
+		fmt.Fprintf(os.Stderr, "Invalid access policy: %s\n", *accessPolicy)
+		return 2
+	}
+
+	opts := uploader.DefaultUploadOptions()
+	opts.AccessPolicy = *accessPolicy
+	opts.Desc = *desc
+
+	results, forwarded := forwardUpload(g, env, fs.Args(), opts)
+	if !forwarded {
+		results = uploadFiles(env, fs.Args(), opts)
+	}
+	exitCode := 0
+	for _, r := range results {
+		if r.Error != "" {
+			exitCode = 1
+			continue
+		}
+		if *openURL {
+			if err := platform.New().Opener.Open(r.PermalinkURL); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to open %s: %v\n", r.PermalinkURL, err)
+			}
+		}
+	}
+
+	if err := writeUploadResults(os.Stdout, *format, results); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return exitCode
+}
+
+// uploadFiles はこのプロセスで files をアップロードする
+// 失敗したファイルは標準エラー出力に表示し、結果の Error に理由を入れる
+func uploadFiles(env *commandEnv, files []string, opts *gyazo.UploadOptions) []uploadResult {
+	ctx := context.Background()
+	account := &uploader.Account{Profile: env.config.Profile, Client: env.client, Options: opts}
+	results := make([]uploadResult, 0, len(files))
+	for _, file := range files {
+		var res *gyazo.UploadResponse
+		var err error
+		if file == "-" {
+			res, err = uploader.Upload(ctx, account, env.history, "stdin", os.Stdin)
+		} else {
+			res, err = uploader.UploadFile(ctx, account, env.history, file)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to upload %s: %v\n", file, err)
+			results = append(results, uploadResult{File: file, Error: err.Error()})
+			continue
+		}
+		results = append(results, uploadResult{
+			File:         file,
+			ImageID:      res.ImageID,
+			PermalinkURL: res.PermalinkURL,
+			URL:          res.URL,
+		})
+	}
+	return results
+}
+
+// writeUploadResults は results を format の形式で w に書き込む
+// url と markdown では失敗したファイルは出力しない (エラーは標準エラー出力に出している)
+func writeUploadResults(w io.Writer, format string, results []uploadResult) error {
+	if format == config.OutputFormatJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(results)
+	}
+	for _, r := range results {
+		if r.Error != "" {
+			continue
+		}
+		var err error
+		switch format {
+		case config.OutputFormatMarkdown:
+			_, err = fmt.Fprintf(w, "[![Image from Gyazo](%s)](%s)\n", r.URL, r.PermalinkURL)
+		default:
+			_, err = fmt.Fprintln(w, r.PermalinkURL)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}